@@ -0,0 +1,423 @@
+package pathio
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	archiveZip = "zip"
+	archiveTar = "tar"
+
+	// archiveMemberSeparator separates the archive path from the member path
+	archiveMemberSeparator = "!/"
+
+	// rangeReaderAtBlockSize is the minimum size of the ranged reads used to read zip archives
+	// that aren't local files. Reading whole blocks keeps the number of requests down, since
+	// archive/zip reads the central directory and members in small chunks.
+	rangeReaderAtBlockSize = 256 * 1024
+)
+
+// isArchivePath reports whether the path addresses a member of a zip or tar archive, e.g.
+// zip+s3://bucket/bundle.zip!/inner/file.csv or tar+file:///tmp/x.tar.gz!/a.csv
+func isArchivePath(path string) bool {
+	return strings.HasPrefix(path, archiveZip+"+") || strings.HasPrefix(path, archiveTar+"+")
+}
+
+// parseArchivePath parses an archive path (zip+s3://bucket/bundle.zip!/inner/file.csv) and
+// returns a format, archive path, member tuple. file:// archive paths are converted to local
+// paths, and the member is "." for the root of the archive.
+func parseArchivePath(path string) (string, string, string, error) {
+	format, rest, _ := strings.Cut(path, "+")
+	archive, member, found := strings.Cut(rest, archiveMemberSeparator)
+	if !found {
+		archive, found = strings.CutSuffix(rest, "!")
+	}
+	if !found || archive == "" {
+		return "", "", "", fmt.Errorf("invalid archive path %s", path)
+	}
+	archive = strings.TrimPrefix(archive, "file://")
+	member = pathpkg.Clean("/" + member)[1:]
+	if member == "" {
+		member = "."
+	}
+	return format, archive, member, nil
+}
+
+// errArchiveReadOnly is returned for operations that archive paths do not support
+func errArchiveReadOnly(op, path string) error {
+	return fmt.Errorf("%w: cannot %s archive path %s, archive paths are read-only", errors.ErrUnsupported, op, path)
+}
+
+// openArchive opens the archive at the archive path as an fs.FS of its members. It is the
+// caller's responsibility to close the returned io.Closer once done with the fs.FS and
+// anything read from it.
+func (c *Client) openArchive(format, archive string) (fs.FS, io.Closer, error) {
+	switch format {
+	case archiveZip:
+		return c.openZip(archive)
+	case archiveTar:
+		return c.openTar(archive)
+	default:
+		return nil, nil, fmt.Errorf("unsupported archive format %s", format)
+	}
+}
+
+// openZip opens a zip archive. Local archives are read directly, all other archives are read
+// with ranged reads so only the central directory and the members being read are fetched.
+func (c *Client) openZip(archive string) (fs.FS, io.Closer, error) {
+	var readerAt io.ReaderAt
+	var size int64
+	var closer io.Closer = closerFunc(func() error { return nil })
+	if !strings.Contains(archive, "://") {
+		file, err := os.Open(archive)
+		if err != nil {
+			return nil, nil, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		readerAt, size, closer = file, info.Size(), file
+	} else {
+		info, err := c.Stat(archive)
+		if err != nil {
+			return nil, nil, err
+		}
+		readerAt, size = &rangeReaderAt{client: c, path: archive, size: info.Size}, info.Size
+	}
+	zipReader, err := zip.NewReader(readerAt, size)
+	if err != nil {
+		closer.Close()
+		return nil, nil, fmt.Errorf("failed to read zip archive %s: %s", archive, err)
+	}
+	return zipReader, closer, nil
+}
+
+// openTar opens a tar archive, optionally gzip compressed. Tar archives have no index, so
+// the archive is streamed until the requested member is found.
+func (c *Client) openTar(archive string) (fs.FS, io.Closer, error) {
+	rc, err := c.reader(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(rc)
+	var input io.Reader = reader
+	if magic, _ := reader.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		if input, err = gzip.NewReader(reader); err != nil {
+			rc.Close()
+			return nil, nil, fmt.Errorf("failed to read tar archive %s: %s", archive, err)
+		}
+	}
+	return &tarFS{reader: tar.NewReader(input), archive: archive}, rc, nil
+}
+
+// archiveReader returns an io.ReadCloser for an archive member
+func (c *Client) archiveReader(path string) (io.ReadCloser, error) {
+	format, archive, member, err := parseArchivePath(path)
+	if err != nil {
+		return nil, err
+	}
+	fsys, closer, err := c.openArchive(format, archive)
+	if err != nil {
+		return nil, err
+	}
+	file, err := fsys.Open(member)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	return readCloser{file, closerFunc(func() error {
+		err := file.Close()
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})}, nil
+}
+
+// archiveRangeReader returns an io.ReadCloser for a byte range of an archive member. Members
+// are usually compressed, so the bytes before the range are read and discarded.
+func (c *Client) archiveRangeReader(path string, offset, length int64) (io.ReadCloser, error) {
+	rc, err := c.archiveReader(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, rc, offset); err != nil && err != io.EOF {
+		rc.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return rc, nil
+	}
+	return readCloser{io.LimitReader(rc, length), rc}, nil
+}
+
+// lsArchive lists the names of the members in an archive directory. It does not recurse
+func (c *Client) lsArchive(path string) ([]string, error) {
+	format, archive, member, err := parseArchivePath(path)
+	if err != nil {
+		return nil, err
+	}
+	fsys, closer, err := c.openArchive(format, archive)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	entries, err := fs.ReadDir(fsys, member)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]string, len(entries))
+	for i, val := range entries {
+		results[i] = val.Name()
+	}
+	return results, nil
+}
+
+func (c *Client) lsArchiveEntries(path, namePrefix string) ([]listEntry, error) {
+	format, archive, member, err := parseArchivePath(path)
+	if err != nil {
+		return nil, err
+	}
+	fsys, closer, err := c.openArchive(format, archive)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	dirEntries, err := fs.ReadDir(fsys, member)
+	if err != nil {
+		return nil, err
+	}
+	var entries []listEntry
+	for _, val := range dirEntries {
+		if !strings.HasPrefix(val.Name(), namePrefix) {
+			continue
+		}
+		info, err := val.Info()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, listEntry{val.Name(), FileInfo{
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+		}})
+	}
+	return entries, nil
+}
+
+func (c *Client) statArchive(path string) (FileInfo, error) {
+	format, archive, member, err := parseArchivePath(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	fsys, closer, err := c.openArchive(format, archive)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	defer closer.Close()
+	info, err := fs.Stat(fsys, member)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}, nil
+}
+
+func (c *Client) existsArchive(path string) (bool, error) {
+	_, err := c.statArchive(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// rangeReaderAt implements io.ReaderAt on top of ranged reads of a path. It reads whole blocks
+// and keeps the most recently read block, since archive/zip issues many small sequential reads.
+type rangeReaderAt struct {
+	mu          sync.Mutex
+	client      *Client
+	path        string
+	size        int64
+	blockOffset int64
+	block       []byte
+}
+
+func (r *rangeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && off < r.size {
+		if off < r.blockOffset || off >= r.blockOffset+int64(len(r.block)) {
+			if err := r.fetch(off, int64(len(p)-n)); err != nil {
+				return n, err
+			}
+		}
+		copied := copy(p[n:], r.block[off-r.blockOffset:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch reads the block starting at off that covers at least want bytes
+func (r *rangeReaderAt) fetch(off, want int64) error {
+	length := max(want, rangeReaderAtBlockSize)
+	length = min(length, r.size-off)
+	rc, err := r.client.ReadRange(r.path, off, length)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	block := make([]byte, length)
+	if _, err := io.ReadFull(rc, block); err != nil {
+		return err
+	}
+	r.blockOffset, r.block = off, block
+	return nil
+}
+
+// tarFS is a single use fs.FS over a streamed tar archive. Opening a member consumes the
+// archive up to that member; it supports the Open, Stat and ReadDir calls made by pathio.
+type tarFS struct {
+	reader  *tar.Reader
+	archive string
+}
+
+// Open streams the archive until the named member, which must be a regular file
+func (t *tarFS) Open(name string) (fs.File, error) {
+	for {
+		header, err := t.reader.Next()
+		if err == io.EOF {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive %s: %s", t.archive, err)
+		}
+		if tarMemberName(header.Name) != name {
+			continue
+		}
+		if header.Typeflag == tar.TypeDir {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+		}
+		return &tarFile{Reader: t.reader, info: header.FileInfo()}, nil
+	}
+}
+
+// Stat streams the archive until the named member, or a member inside the named directory
+func (t *tarFS) Stat(name string) (fs.FileInfo, error) {
+	for {
+		header, err := t.reader.Next()
+		if err == io.EOF {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive %s: %s", t.archive, err)
+		}
+		memberName := tarMemberName(header.Name)
+		if memberName == name {
+			return header.FileInfo(), nil
+		}
+		if name == "." || strings.HasPrefix(memberName, name+"/") {
+			// Directories don't need their own entry in a tar archive
+			return tarDirEntry(pathpkg.Base(name)), nil
+		}
+	}
+}
+
+// ReadDir streams the whole archive and returns the members directly inside the named directory
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	seen := map[string]bool{}
+	found := name == "."
+	for {
+		header, err := t.reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive %s: %s", t.archive, err)
+		}
+		memberName := tarMemberName(header.Name)
+		if memberName == name {
+			if header.Typeflag != tar.TypeDir {
+				return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+			}
+			found = true
+			continue
+		}
+		rel := memberName
+		if name != "." {
+			var ok bool
+			if rel, ok = strings.CutPrefix(memberName, name+"/"); !ok {
+				continue
+			}
+		}
+		found = true
+		child, rest, isNested := strings.Cut(rel, "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		if isNested && rest != "" {
+			entries = append(entries, tarDirEntry(child))
+		} else {
+			entries = append(entries, fs.FileInfoToDirEntry(header.FileInfo()))
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+// tarMemberName normalizes a tar header name into an fs.FS name
+func tarMemberName(name string) string {
+	cleaned := pathpkg.Clean("/" + name)[1:]
+	if cleaned == "" {
+		return "."
+	}
+	return cleaned
+}
+
+type tarFile struct {
+	io.Reader
+	info fs.FileInfo
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *tarFile) Close() error               { return nil }
+
+// tarDirEntry is the entry for a directory that only exists implicitly in a tar archive
+type tarDirEntry string
+
+func (d tarDirEntry) Name() string               { return string(d) }
+func (d tarDirEntry) IsDir() bool                { return true }
+func (d tarDirEntry) Type() fs.FileMode          { return fs.ModeDir }
+func (d tarDirEntry) Info() (fs.FileInfo, error) { return d, nil }
+
+// tarDirEntry also implements fs.FileInfo
+func (d tarDirEntry) Size() int64        { return 0 }
+func (d tarDirEntry) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (d tarDirEntry) ModTime() time.Time { return time.Time{} }
+func (d tarDirEntry) Sys() any           { return nil }
@@ -0,0 +1,170 @@
+package pathio
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var archiveTestFiles = []struct {
+	name, body string
+}{
+	{"a.csv", "id\n1\n"},
+	{"inner/file.csv", "id\n2\n"},
+	{"inner/deeper/b.csv", "id\n3\n"},
+}
+
+func writeTestZip(t *testing.T, path string, padding int) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, file := range archiveTestFiles {
+		fw, err := w.Create(file.name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(file.body))
+		require.NoError(t, err)
+	}
+	// A large, incompressible member so reading the other members can't fetch the whole archive
+	fw, err := w.CreateHeader(&zip.FileHeader{Name: "padding.bin", Method: zip.Store})
+	require.NoError(t, err)
+	_, err = fw.Write(bytes.Repeat([]byte{0}, padding))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func writeTestTarGz(t *testing.T, path string) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	w := tar.NewWriter(gw)
+	for _, file := range archiveTestFiles {
+		require.NoError(t, w.WriteHeader(&tar.Header{
+			Name:    "./" + file.name,
+			Mode:    0644,
+			Size:    int64(len(file.body)),
+			ModTime: time.Now(),
+		}))
+		_, err := w.Write([]byte(file.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, gw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestParseArchivePath(t *testing.T) {
+	format, archive, member, err := parseArchivePath("zip+s3://bucket/bundle.zip!/inner/file.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "zip", format)
+	assert.Equal(t, "s3://bucket/bundle.zip", archive)
+	assert.Equal(t, "inner/file.csv", member)
+
+	format, archive, member, err = parseArchivePath("tar+file:///tmp/x.tar.gz!/")
+	assert.NoError(t, err)
+	assert.Equal(t, "tar", format)
+	assert.Equal(t, "/tmp/x.tar.gz", archive)
+	assert.Equal(t, ".", member)
+
+	_, archive, member, err = parseArchivePath("zip+/tmp/x.zip!")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/x.zip", archive)
+	assert.Equal(t, ".", member)
+
+	_, _, _, err = parseArchivePath("zip+s3://bucket/bundle.zip")
+	assert.EqualError(t, err, "invalid archive path zip+s3://bucket/bundle.zip")
+}
+
+func TestLocalArchives(t *testing.T) {
+	dir := t.TempDir()
+	writeTestZip(t, filepath.Join(dir, "bundle.zip"), 16)
+	writeTestTarGz(t, filepath.Join(dir, "bundle.tar.gz"))
+	client := &Client{ctx: context.Background()}
+
+	for _, prefix := range []string{"zip+file://" + dir + "/bundle.zip", "tar+" + dir + "/bundle.tar.gz"} {
+		t.Run(prefix, func(t *testing.T) {
+			for _, file := range archiveTestFiles {
+				reader, err := client.Reader(prefix + "!/" + file.name)
+				require.NoError(t, err)
+				body, err := io.ReadAll(reader)
+				assert.NoError(t, err)
+				assert.NoError(t, reader.Close())
+				assert.Equal(t, file.body, string(body))
+
+				exists, err := client.Exists(prefix + "!/" + file.name)
+				assert.NoError(t, err)
+				assert.True(t, exists)
+			}
+
+			files, err := client.ListFiles(prefix + "!/inner")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{"file.csv", "deeper"}, files)
+
+			exists, err := client.Exists(prefix + "!/inner/deeper")
+			assert.NoError(t, err)
+			assert.True(t, exists)
+
+			exists, err = client.Exists(prefix + "!/missing.csv")
+			assert.NoError(t, err)
+			assert.False(t, exists)
+
+			_, err = client.Reader(prefix + "!/missing.csv")
+			assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+			err = client.Write(prefix+"!/a.csv", []byte("data"))
+			assert.True(t, errors.Is(err, errors.ErrUnsupported))
+		})
+	}
+}
+
+func TestRemoteZipUsesRangedReads(t *testing.T) {
+	dir := t.TempDir()
+	padding := 4 * rangeReaderAtBlockSize
+	writeTestZip(t, filepath.Join(dir, "bundle.zip"), padding)
+	var bytesServed int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, err := os.Open(filepath.Join(dir, "bundle.zip"))
+		require.NoError(t, err)
+		defer file.Close()
+		info, _ := file.Stat()
+		http.ServeContent(countingWriter{w, &bytesServed}, r, "bundle.zip", info.ModTime(), file)
+	}))
+	defer server.Close()
+	client := &Client{ctx: context.Background(), HTTPClient: server.Client()}
+
+	reader, err := client.Reader("zip+" + server.URL + "/bundle.zip!/inner/file.csv")
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, "id\n2\n", string(body))
+	assert.Less(t, atomic.LoadInt64(&bytesServed), int64(padding))
+
+	files, err := client.ListFiles("zip+" + server.URL + "/bundle.zip!/")
+	assert.NoError(t, err)
+	assert.Equal(t, "a.csv,inner,padding.bin", strings.Join(files, ","))
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	count *int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	atomic.AddInt64(w.count, int64(len(p)))
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,310 @@
+package pathio
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Outcomes of an AuditRecord.
+const (
+	// AuditPending is recorded before the operation starts, so it is in the log even if the
+	// process dies during the operation.
+	AuditPending = "pending"
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditRecord is a record of the audit log of a Client. Each operation is recorded twice with
+// the same ID: with the AuditPending outcome before it starts, and with its outcome once it
+// completes.
+type AuditRecord struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Principal is who made the call, from AuditLog.Principal.
+	Principal string `json:"principal,omitempty"`
+	// Op is "write", "delete", "copy" for a copy from Src to Path, "tag" for SetTags,
+	// "legal-hold" or "remove-legal-hold" for SetLegalHold, "restore" for RestoreArchived, or
+	// "abort-upload" for each upload aborted by AbortStaleUploads.
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Src  string `json:"src,omitempty"`
+	// Size is the number of bytes written or copied, or the size of the deleted object when
+	// known.
+	Size int64 `json:"size"`
+	// Checksum is "md5:" followed by the hex MD5 of the content when known. Writes of a reader
+	// are hashed as they upload, so only their outcome has it.
+	Checksum string `json:"checksum,omitempty"`
+	// VersionID is the S3 version of the object written, in versioned buckets.
+	VersionID string `json:"version_id,omitempty"`
+	Outcome   string `json:"outcome"`
+	Error     string `json:"error,omitempty"`
+}
+
+// AuditSink stores the records of an audit log. Record must be safe for concurrent use.
+type AuditSink interface {
+	Record(record AuditRecord) error
+}
+
+// AuditLog records the operations that change files or objects of a Client in a sink, set with
+// Client.Audit. An operation doesn't start unless its pending record was stored.
+type AuditLog struct {
+	Sink AuditSink
+	// Principal identifies who made the calls. For clients created with NewClient it defaults
+	// to the ARN of the caller identity of their AWS credentials, looked up once with STS, and
+	// is otherwise empty.
+	Principal string
+
+	principalOnce sync.Once
+	principal     string
+}
+
+// audited records the operation in the audit log before and after calling mutate, if the
+// client has one. record has the operation and paths, and the size and checksum when known
+// before the operation.
+func (c *Client) audited(record AuditRecord, mutate func() error) error {
+	return c.auditedWithResult(record, mutate, nil)
+}
+
+// auditedWithResult is audited, calling result once the operation succeeded to set what it
+// learned of the written object
+func (c *Client) auditedWithResult(record AuditRecord, mutate func() error, result func(*AuditRecord)) error {
+	if c.Audit == nil {
+		return mutate()
+	}
+	record.ID = newAuditID()
+	record.Principal = c.auditPrincipal()
+	record.Time = time.Now().UTC()
+	record.Outcome = AuditPending
+	if record.Op == "delete" {
+		// Best effort, to record what was deleted
+		if info, err := c.Stat(record.Path); err == nil {
+			record.Size, record.Checksum = info.Size, etagChecksum(record.Path, info.ETag)
+		}
+	}
+	if err := c.Audit.Sink.Record(record); err != nil {
+		return fmt.Errorf("failed to record %s of %s in the audit log: %s", record.Op, record.Path, err)
+	}
+
+	err := mutate()
+	record.Time = time.Now().UTC()
+	if err != nil {
+		record.Outcome, record.Error = AuditFailure, err.Error()
+	} else {
+		record.Outcome = AuditSuccess
+		if result != nil {
+			result(&record)
+		}
+		if record.Op == "write" || record.Op == "copy" {
+			c.fillAuditResult(&record)
+		}
+	}
+	if auditErr := c.Audit.Sink.Record(record); auditErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to record the outcome of the %s of %s in the audit log: %s", record.Op, record.Path, auditErr))
+	}
+	return err
+}
+
+// fillAuditResult sets what is known of the written object once the operation succeeded. S3
+// objects are read back with a HEAD request for their version ID.
+func (c *Client) fillAuditResult(record *AuditRecord) {
+	if record.Checksum != "" && !isS3Path(record.Path) {
+		return
+	}
+	info, err := c.Stat(record.Path)
+	if err != nil {
+		return
+	}
+	record.VersionID = info.VersionID
+	if record.Checksum == "" {
+		record.Size, record.Checksum = info.Size, etagChecksum(record.Path, info.ETag)
+	}
+}
+
+// newWriteAuditRecord returns the audit record of a write of input, reading it once for its
+// size and checksum
+func newWriteAuditRecord(path string, input io.ReadSeeker) (AuditRecord, error) {
+	hash := md5.New()
+	size, err := io.Copy(hash, input)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return AuditRecord{}, err
+	}
+	return AuditRecord{Op: "write", Path: path, Size: size, Checksum: "md5:" + hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// auditedWrite writes input to the path with write, recording it in the audit log
+func (c *Client) auditedWrite(path string, input []byte, write func() error) error {
+	if c.Audit == nil {
+		return write()
+	}
+	record, err := newWriteAuditRecord(path, bytes.NewReader(input))
+	if err != nil {
+		return err
+	}
+	return c.audited(record, write)
+}
+
+// hashingReadSeeker hashes the bytes read through it in order, so a write can be hashed as it
+// uploads. Bytes reread after a seek back aren't hashed again, and bytes skipped by a seek
+// forward leave the hash incomplete until they are read.
+type hashingReadSeeker struct {
+	io.ReadSeeker
+	hash   hash.Hash
+	pos    int64
+	hashed int64
+}
+
+func newHashingReadSeeker(input io.ReadSeeker) *hashingReadSeeker {
+	return &hashingReadSeeker{ReadSeeker: input, hash: md5.New()}
+}
+
+func (r *hashingReadSeeker) Read(b []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(b)
+	if end := r.pos + int64(n); r.pos <= r.hashed && end > r.hashed {
+		r.hash.Write(b[r.hashed-r.pos : n])
+		r.hashed = end
+	}
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *hashingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		r.pos = pos
+	}
+	return pos, err
+}
+
+// checksum returns the checksum of the input if all size bytes of it were hashed, or ""
+func (r *hashingReadSeeker) checksum(size int64) string {
+	if r.hashed != size {
+		return ""
+	}
+	return "md5:" + hex.EncodeToString(r.hash.Sum(nil))
+}
+
+// etagChecksum returns the checksum of an S3 object whose ETag is its MD5, or ""
+func etagChecksum(path, etag string) string {
+	if !isS3Path(path) || !md5ETagPattern.MatchString(etag) {
+		return ""
+	}
+	return "md5:" + strings.Trim(etag, `"`)
+}
+
+func newAuditID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// auditPrincipal returns the principal of the audit records
+func (c *Client) auditPrincipal() string {
+	a := c.Audit
+	if a.Principal != "" {
+		return a.Principal
+	}
+	a.principalOnce.Do(func() {
+		if c.providedConfig == nil {
+			return
+		}
+		resp, err := sts.NewFromConfig(*c.providedConfig, func(o *sts.Options) {
+			if o.Region == "" {
+				o.Region = "us-east-1"
+			}
+		}).GetCallerIdentity(c.ctx, &sts.GetCallerIdentityInput{})
+		if err == nil {
+			a.principal = aws.ToString(resp.Arn)
+		}
+	})
+	return a.principal
+}
+
+// JSONLinesAuditSink writes each audit record as a line of JSON.
+type JSONLinesAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesAuditSink returns a sink writing the records to w.
+func NewJSONLinesAuditSink(w io.Writer) *JSONLinesAuditSink {
+	return &JSONLinesAuditSink{w: w}
+}
+
+// NewFileAuditSink returns a sink appending the records to a local file, creating it if needed.
+// Each record is synced to disk before the operation continues. Close the sink to close the
+// file.
+func NewFileAuditSink(path string) (*JSONLinesAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLinesAuditSink{w: file}, nil
+}
+
+// Record writes the record as a line of JSON, syncing it to disk when writing to a file.
+func (s *JSONLinesAuditSink) Record(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if file, ok := s.w.(*os.File); ok {
+		return file.Sync()
+	}
+	return nil
+}
+
+// Close closes the writer of the sink if it is an io.Closer.
+func (s *JSONLinesAuditSink) Close() error {
+	if closer, ok := s.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// PathAuditSink writes each audit record as a JSON object under a directory or S3 prefix, since
+// S3 objects can't be appended to. The objects are named by time, so listing the prefix returns
+// them in order.
+type PathAuditSink struct {
+	// Client writes the records. It must not have an audit log itself.
+	Client *Client
+	Prefix string
+}
+
+// Record writes the record to Prefix/<time>-<id>-<outcome>.json.
+func (s *PathAuditSink) Record(record AuditRecord) error {
+	if s.Client.Audit != nil {
+		return errors.New("the client of a PathAuditSink can't have an audit log")
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	p, err := parsePath(s.Prefix)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s-%s.json", record.Time.Format("20060102T150405.000000000Z"), record.ID, record.Outcome)
+	return s.Client.WriteReader(p.Join(name).String(), bytes.NewReader(body))
+}
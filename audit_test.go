@@ -0,0 +1,249 @@
+package pathio
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAuditSink keeps the audit records in memory, failing once it holds limit records if
+// limit is set
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+	limit   int
+}
+
+func (s *memoryAuditSink) Record(record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit > 0 && len(s.records) >= s.limit {
+		return errors.New("sink is full")
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+// summary returns the records without their IDs and times, checking that each operation was
+// recorded twice with the same ID
+func (s *memoryAuditSink) summary(t *testing.T) []AuditRecord {
+	var summary []AuditRecord
+	for i, record := range s.records {
+		assert.NotEmpty(t, record.ID)
+		assert.False(t, record.Time.IsZero())
+		if i%2 == 1 {
+			assert.Equal(t, s.records[i-1].ID, record.ID)
+		}
+		record.ID, record.Time = "", time.Time{}
+		summary = append(summary, record)
+	}
+	return summary
+}
+
+func md5Checksum(body string) string {
+	return fmt.Sprintf("md5:%x", md5.Sum([]byte(body)))
+}
+
+func TestAudit(t *testing.T) {
+	handler := newFakeS3Handler()
+	handler.put("bucket", "old.csv", "old")
+	c := newFakeS3Client(handler)
+	sink := &memoryAuditSink{}
+	const principal = "arn:aws:iam::123456789012:user/alice"
+	c.Audit = &AuditLog{Sink: sink, Principal: principal}
+	dir := t.TempDir()
+	local := filepath.Join(dir, "out.csv")
+	missing := filepath.Join(dir, "missing.csv")
+
+	require.NoError(t, c.Write("s3://bucket/new.csv", []byte("a,b")))
+	require.NoError(t, c.Delete("s3://bucket/old.csv"))
+	require.NoError(t, c.Move("s3://bucket/new.csv", local))
+	deleteErr := c.Delete(missing)
+	require.Error(t, deleteErr)
+
+	abc := md5Checksum("a,b")
+	assert.Equal(t, []AuditRecord{
+		{Principal: principal, Op: "write", Path: "s3://bucket/new.csv", Size: 3, Outcome: AuditPending},
+		{Principal: principal, Op: "write", Path: "s3://bucket/new.csv", Size: 3, Checksum: abc, Outcome: AuditSuccess},
+		{Principal: principal, Op: "delete", Path: "s3://bucket/old.csv", Size: 3, Checksum: md5Checksum("old"), Outcome: AuditPending},
+		{Principal: principal, Op: "delete", Path: "s3://bucket/old.csv", Size: 3, Checksum: md5Checksum("old"), Outcome: AuditSuccess},
+		{Principal: principal, Op: "copy", Path: local, Src: "s3://bucket/new.csv", Outcome: AuditPending},
+		{Principal: principal, Op: "copy", Path: local, Src: "s3://bucket/new.csv", Size: 3, Outcome: AuditSuccess},
+		{Principal: principal, Op: "delete", Path: "s3://bucket/new.csv", Size: 3, Checksum: abc, Outcome: AuditPending},
+		{Principal: principal, Op: "delete", Path: "s3://bucket/new.csv", Size: 3, Checksum: abc, Outcome: AuditSuccess},
+		{Principal: principal, Op: "delete", Path: missing, Outcome: AuditPending},
+		{Principal: principal, Op: "delete", Path: missing, Outcome: AuditFailure, Error: deleteErr.Error()},
+	}, sink.summary(t))
+}
+
+// countingReadSeeker counts the bytes read from it
+type countingReadSeeker struct {
+	io.ReadSeeker
+	read int64
+}
+
+func (r *countingReadSeeker) Read(b []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(b)
+	r.read += int64(n)
+	return n, err
+}
+
+func TestAuditHashesWhileWriting(t *testing.T) {
+	c := &Client{ctx: context.Background()}
+	sink := &memoryAuditSink{}
+	c.Audit = &AuditLog{Sink: sink}
+	path := filepath.Join(t.TempDir(), "out.csv")
+
+	input := &countingReadSeeker{ReadSeeker: strings.NewReader("a,b\nc,d\n")}
+	require.NoError(t, c.WriteReader(path, input))
+	assert.Equal(t, int64(8), input.read)
+	assert.Equal(t, []AuditRecord{
+		{Op: "write", Path: path, Size: 8, Outcome: AuditPending},
+		{Op: "write", Path: path, Size: 8, Checksum: md5Checksum("a,b\nc,d\n"), Outcome: AuditSuccess},
+	}, sink.summary(t))
+}
+
+func TestAuditOtherOps(t *testing.T) {
+	handler := newFakeS3Handler()
+	c := newFakeS3Client(handler)
+	path := "s3://bucket/archive/2019.csv"
+	require.NoError(t, c.WriteWithOptions(path, []byte("old"), WriteOptions{StorageClass: "GLACIER"}))
+	handler.now = func() time.Time { return time.Now().Add(-10 * 24 * time.Hour) }
+	_, err := handler.CreateMultipartUpload(c.ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("exports/old")})
+	require.NoError(t, err)
+	handler.now = time.Now
+	sink := &memoryAuditSink{}
+	c.Audit = &AuditLog{Sink: sink}
+
+	require.NoError(t, c.SetTags(path, map[string]string{"team": "data"}))
+	require.NoError(t, c.SetLegalHold(path, true))
+	require.NoError(t, c.SetLegalHold(path, false))
+	require.NoError(t, c.RestoreArchived(path, 7, RestoreTierBulk))
+	_, err = c.AbortStaleUploads("s3://bucket/exports/", 7*24*time.Hour)
+	require.NoError(t, err)
+
+	var ops []string
+	for _, record := range sink.summary(t) {
+		assert.Equal(t, AuditRecord{Op: record.Op, Path: record.Path, Outcome: record.Outcome}, record)
+		ops = append(ops, record.Op+" "+record.Path+" "+record.Outcome)
+	}
+	assert.Equal(t, []string{
+		"tag " + path + " pending", "tag " + path + " success",
+		"legal-hold " + path + " pending", "legal-hold " + path + " success",
+		"remove-legal-hold " + path + " pending", "remove-legal-hold " + path + " success",
+		"restore " + path + " pending", "restore " + path + " success",
+		"abort-upload s3://bucket/exports/old pending", "abort-upload s3://bucket/exports/old success",
+	}, ops)
+}
+
+func TestAuditConditionalAndSync(t *testing.T) {
+	handler := newFakeS3Handler()
+	c := newFakeS3Client(handler)
+	sink := &memoryAuditSink{}
+	c.Audit = &AuditLog{Sink: sink}
+
+	require.NoError(t, c.WriteIfAbsent("s3://bucket/src/a.csv", []byte("a")))
+	assert.ErrorIs(t, c.WriteIfAbsent("s3://bucket/src/a.csv", []byte("b")), ErrPreconditionFailed)
+	_, err := c.Sync("s3://bucket/src", "s3://bucket/dst", SyncOptions{})
+	require.NoError(t, err)
+
+	records := sink.summary(t)
+	require.Len(t, records, 6)
+	assert.Equal(t, AuditSuccess, records[1].Outcome)
+	assert.Equal(t, AuditFailure, records[3].Outcome)
+	assert.Equal(t, AuditRecord{
+		Op: "copy", Path: "s3://bucket/dst/a.csv", Src: "s3://bucket/src/a.csv", Size: 1,
+		Checksum: md5Checksum("a"), Outcome: AuditSuccess,
+	}, records[5])
+}
+
+func TestAuditSinkFailure(t *testing.T) {
+	handler := newFakeS3Handler()
+	c := newFakeS3Client(handler)
+
+	// The operation doesn't start if its pending record can't be stored
+	c.Audit = &AuditLog{Sink: &memoryAuditSink{limit: 2}}
+	require.NoError(t, c.Write("s3://bucket/a.csv", []byte("a")))
+	err := c.Write("s3://bucket/b.csv", []byte("b"))
+	assert.ErrorContains(t, err, "sink is full")
+	assert.Equal(t, []string{"bucket/a.csv"}, fakeObjectIDs(handler))
+
+	// The error of the outcome record is returned after the operation
+	c.Audit = &AuditLog{Sink: &memoryAuditSink{limit: 1}}
+	err = c.Write("s3://bucket/c.csv", []byte("c"))
+	assert.ErrorContains(t, err, "failed to record the outcome of the write of s3://bucket/c.csv")
+	assert.Equal(t, []string{"bucket/a.csv", "bucket/c.csv"}, fakeObjectIDs(handler))
+}
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileAuditSink(path)
+	require.NoError(t, err)
+	c := &Client{Audit: &AuditLog{Sink: sink, Principal: "me"}}
+	local := filepath.Join(t.TempDir(), "a.csv")
+	require.NoError(t, c.Write(local, []byte("a,b")))
+	require.NoError(t, sink.Close())
+
+	// A second sink appends to the file
+	sink, err = NewFileAuditSink(path)
+	require.NoError(t, err)
+	c.Audit.Sink = sink
+	require.NoError(t, c.Delete(local))
+	require.NoError(t, sink.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record AuditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, records, 4)
+	assert.Equal(t, "me", records[0].Principal)
+	assert.Equal(t, md5Checksum("a,b"), records[1].Checksum)
+	assert.Equal(t, []string{"write", "write", "delete", "delete"},
+		[]string{records[0].Op, records[1].Op, records[2].Op, records[3].Op})
+	assert.Equal(t, AuditSuccess, records[3].Outcome)
+}
+
+func TestPathAuditSink(t *testing.T) {
+	handler := newFakeS3Handler()
+	sink := &PathAuditSink{Client: newFakeS3Client(handler), Prefix: "s3://audit/log/"}
+	c := newFakeS3Client(handler)
+	c.Audit = &AuditLog{Sink: sink}
+
+	require.NoError(t, c.Write("s3://bucket/a.csv", []byte("a")))
+	ids := fakeObjectIDs(handler)
+	require.Len(t, ids, 3)
+	assert.Regexp(t, `^audit/log/\d{8}T\d{6}\.\d{9}Z-[0-9a-f]{32}-pending\.json$`, ids[0])
+	assert.Regexp(t, `^audit/log/\d{8}T\d{6}\.\d{9}Z-[0-9a-f]{32}-success\.json$`, ids[1])
+	assert.Equal(t, "bucket/a.csv", ids[2])
+
+	var record AuditRecord
+	require.NoError(t, json.Unmarshal([]byte(readFakeObject(t, c, "s3://"+ids[1])), &record))
+	assert.Equal(t, "s3://bucket/a.csv", record.Path)
+	assert.Equal(t, AuditSuccess, record.Outcome)
+
+	// The sink's client can't be audited itself
+	sink.Client = c
+	assert.ErrorContains(t, c.Write("s3://bucket/b.csv", []byte("b")), "can't have an audit log")
+}
@@ -0,0 +1,437 @@
+package pathio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RevalidationPolicy is how a CachedClient checks that a cached object is still current.
+type RevalidationPolicy int
+
+const (
+	// RevalidateIfNoneMatch sends a GET with If-None-Match, which also downloads the object in
+	// the same request if it changed.
+	RevalidateIfNoneMatch RevalidationPolicy = iota
+	// RevalidateHead compares the ETag from a HeadObject request, and only downloads the object
+	// if it changed.
+	RevalidateHead
+	// RevalidateNever serves cached objects until they are evicted or overwritten through the
+	// CachedClient.
+	RevalidateNever
+)
+
+// CacheOptions configures a CachedClient.
+type CacheOptions struct {
+	// Dir is the directory holding the cache. It can be shared by several processes.
+	Dir string
+	// MaxBytes caps the size of the cached objects; the least recently used objects are evicted
+	// to stay below it. Zero means no cap.
+	MaxBytes int64
+	// Revalidation is how cached objects are checked once they are older than MaxAge.
+	Revalidation RevalidationPolicy
+	// MaxAge is how long a cached object is served after it was last validated without checking
+	// S3 again. Zero checks on every read.
+	MaxAge time.Duration
+}
+
+// CacheStats counts the cache activity of a CachedClient since it was created.
+type CacheStats struct {
+	// Hits are reads served from the cache, including after a successful revalidation.
+	Hits int64
+	// Misses are reads that had to download the object.
+	Misses int64
+	// Revalidations are the requests made to check whether a cached object changed.
+	Revalidations int64
+	// Evictions are cached objects removed to stay below MaxBytes.
+	Evictions int64
+}
+
+// CachedClient is a Client whose Reader serves S3 objects from a read-through cache in a local
+// directory, keyed by bucket, key and ETag. Every method that changes an object, such as writes,
+// deletes, copies, moves, syncs and tag changes, invalidates its cached copy; other paths and
+// methods are passed to the Client.
+type CachedClient struct {
+	*Client
+	opts CacheOptions
+
+	// mu serializes cache updates within the process, on top of the directory lock that
+	// serializes them across processes
+	mu            sync.Mutex
+	hits          atomic.Int64
+	misses        atomic.Int64
+	revalidations atomic.Int64
+	evictions     atomic.Int64
+}
+
+// cacheEntry is the metadata file of a cached object
+type cacheEntry struct {
+	Path        string    `json:"path"`
+	ETag        string    `json:"etag"`
+	Size        int64     `json:"size"`
+	ValidatedAt time.Time `json:"validated_at"`
+}
+
+const (
+	cacheDataExt  = ".data"
+	cacheEntryExt = ".json"
+	cacheLockFile = ".lock"
+)
+
+// NewCachedClient returns a CachedClient that caches the S3 objects read through client in
+// opts.Dir, creating the directory if needed.
+func NewCachedClient(client *Client, opts CacheOptions) (*CachedClient, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("invalid cache options: Dir is required")
+	}
+	if err := os.MkdirAll(opts.Dir, client.dirMode()); err != nil {
+		return nil, err
+	}
+	return &CachedClient{Client: client, opts: opts}, nil
+}
+
+// Stats returns the cache activity of the client.
+func (cc *CachedClient) Stats() CacheStats {
+	return CacheStats{
+		Hits:          cc.hits.Load(),
+		Misses:        cc.misses.Load(),
+		Revalidations: cc.revalidations.Load(),
+		Evictions:     cc.evictions.Load(),
+	}
+}
+
+// Reader returns an io.ReadCloser for the specified path. S3 objects are served from the cache
+// when the cached copy is current, and are otherwise downloaded into the cache first. It is the
+// caller's responsibility to close rc.
+func (cc *CachedClient) Reader(path string) (rc io.ReadCloser, err error) {
+	if !isS3Path(path) {
+		return cc.Client.Reader(path)
+	}
+	entry, file := cc.lookup(path)
+	if file == nil {
+		cc.misses.Add(1)
+		body, etag, err := cc.Client.ReaderIfNoneMatch(path, "")
+		if err != nil {
+			return nil, err
+		}
+		return cc.fill(path, body, etag)
+	}
+	if cc.opts.Revalidation == RevalidateNever || time.Since(entry.ValidatedAt) < cc.opts.MaxAge {
+		cc.hits.Add(1)
+		return file, nil
+	}
+
+	cc.revalidations.Add(1)
+	switch cc.opts.Revalidation {
+	case RevalidateHead:
+		info, err := cc.Client.Stat(path)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if info.ETag == entry.ETag {
+			cc.hits.Add(1)
+			cc.markValidated(entry)
+			return file, nil
+		}
+		file.Close()
+		cc.misses.Add(1)
+		body, etag, err := cc.Client.ReaderIfNoneMatch(path, "")
+		if err != nil {
+			return nil, err
+		}
+		return cc.fill(path, body, etag)
+	default:
+		body, etag, err := cc.Client.ReaderIfNoneMatch(path, entry.ETag)
+		if errors.Is(err, ErrNotModified) {
+			cc.hits.Add(1)
+			cc.markValidated(entry)
+			return file, nil
+		}
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		cc.misses.Add(1)
+		return cc.fill(path, body, etag)
+	}
+}
+
+// Write writes a byte array to the specified path and invalidates its cached copy.
+func (cc *CachedClient) Write(path string, input []byte) error {
+	defer cc.invalidate(path)
+	return cc.Client.Write(path, input)
+}
+
+// WriteReader writes the input to the specified path and invalidates its cached copy.
+func (cc *CachedClient) WriteReader(path string, input io.ReadSeeker) error {
+	defer cc.invalidate(path)
+	return cc.Client.WriteReader(path, input)
+}
+
+// Delete deletes the object at the specified path and its cached copy.
+func (cc *CachedClient) Delete(path string) error {
+	defer cc.invalidate(path)
+	return cc.Client.Delete(path)
+}
+
+// Copy copies the object at src to dst and invalidates the cached copy of dst.
+func (cc *CachedClient) Copy(src, dst string) error {
+	defer cc.invalidate(dst)
+	return cc.Client.Copy(src, dst)
+}
+
+// Move moves the object at src to dst and invalidates the cached copies of both.
+func (cc *CachedClient) Move(src, dst string) error {
+	defer cc.invalidate(src)
+	defer cc.invalidate(dst)
+	return cc.Client.Move(src, dst)
+}
+
+// WriteWithOptions writes a byte array to the specified path and invalidates its cached copy.
+func (cc *CachedClient) WriteWithOptions(path string, input []byte, opts WriteOptions) error {
+	defer cc.invalidate(path)
+	return cc.Client.WriteWithOptions(path, input, opts)
+}
+
+// WriteReaderWithOptions writes the input to the specified path and invalidates its cached copy.
+func (cc *CachedClient) WriteReaderWithOptions(path string, input io.ReadSeeker, opts WriteOptions) error {
+	defer cc.invalidate(path)
+	return cc.Client.WriteReaderWithOptions(path, input, opts)
+}
+
+// WriteIfAbsent writes input to the path if nothing exists there and invalidates its cached copy.
+func (cc *CachedClient) WriteIfAbsent(path string, input []byte) error {
+	defer cc.invalidate(path)
+	return cc.Client.WriteIfAbsent(path, input)
+}
+
+// WriteIfMatch writes input to the path if its ETag matches and invalidates its cached copy.
+func (cc *CachedClient) WriteIfMatch(path string, input []byte, etag string) error {
+	defer cc.invalidate(path)
+	return cc.Client.WriteIfMatch(path, input, etag)
+}
+
+// Sync makes the tree under dst mirror the tree under src and invalidates the cached copies of
+// the files it copied or deleted.
+func (cc *CachedClient) Sync(src, dst string, opts SyncOptions) (*SyncResult, error) {
+	result, err := cc.Client.Sync(src, dst, opts)
+	if result != nil {
+		for _, rel := range append(append([]string{}, result.Copied...), result.Deleted...) {
+			cc.invalidate(joinTreePath(dst, rel))
+		}
+	}
+	return result, err
+}
+
+// SetTags replaces the tags of the object at the path and invalidates its cached copy.
+func (cc *CachedClient) SetTags(path string, tags map[string]string) error {
+	defer cc.invalidate(path)
+	return cc.Client.SetTags(path, tags)
+}
+
+// SetLegalHold turns the legal hold of the object at the path on or off and invalidates its
+// cached copy.
+func (cc *CachedClient) SetLegalHold(path string, on bool) error {
+	defer cc.invalidate(path)
+	return cc.Client.SetLegalHold(path, on)
+}
+
+// RestoreArchived starts restoring the archived object at the path and invalidates its cached
+// copy.
+func (cc *CachedClient) RestoreArchived(path string, days int32, tier RestoreTier) error {
+	defer cc.invalidate(path)
+	return cc.Client.RestoreArchived(path, days, tier)
+}
+
+// cacheKey returns the file name prefix of the cache files of a path
+func cacheKey(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (cc *CachedClient) entryPath(path string) string {
+	return filepath.Join(cc.opts.Dir, cacheKey(path)+cacheEntryExt)
+}
+
+// dataPath returns the cache file of a version of an object
+func (cc *CachedClient) dataPath(path, etag string) string {
+	sum := sha256.Sum256([]byte(etag))
+	return filepath.Join(cc.opts.Dir, cacheKey(path)+"-"+hex.EncodeToString(sum[:8])+cacheDataExt)
+}
+
+// lookup returns the cache entry of a path and its open data file, or a nil file if the path
+// isn't cached. Reading from the open file is safe even if the entry is evicted meanwhile.
+func (cc *CachedClient) lookup(path string) (cacheEntry, *os.File) {
+	body, err := os.ReadFile(cc.entryPath(path))
+	if err != nil {
+		return cacheEntry{}, nil
+	}
+	var entry cacheEntry
+	if json.Unmarshal(body, &entry) != nil || entry.Path != path {
+		return cacheEntry{}, nil
+	}
+	file, err := os.Open(cc.dataPath(path, entry.ETag))
+	if err != nil {
+		return cacheEntry{}, nil
+	}
+	// The modification time of data files records when they were last used, for eviction
+	now := time.Now()
+	os.Chtimes(file.Name(), now, now)
+	return entry, file
+}
+
+// fill stores a downloaded object in the cache and returns it opened from the cache
+func (cc *CachedClient) fill(path string, body io.ReadCloser, etag string) (io.ReadCloser, error) {
+	if etag == "" {
+		// Without an ETag the object can't be revalidated, so it isn't cached
+		return body, nil
+	}
+	defer body.Close()
+	dataPath := cc.dataPath(path, etag)
+	if err := writeToLocalFile(dataPath, body, cc.fileMode(), cc.dirMode()); err != nil {
+		return nil, fmt.Errorf("failed to cache %s: %s", path, err)
+	}
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	err = cc.withLock(func() error {
+		previous, _ := cc.readEntry(path)
+		if err := cc.writeEntry(cacheEntry{Path: path, ETag: etag, Size: info.Size(), ValidatedAt: time.Now()}); err != nil {
+			return err
+		}
+		if previous.ETag != "" && previous.ETag != etag {
+			os.Remove(cc.dataPath(path, previous.ETag))
+		}
+		return cc.evict()
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// markValidated records that the cached object was found current
+func (cc *CachedClient) markValidated(entry cacheEntry) {
+	cc.withLock(func() error {
+		current, err := cc.readEntry(entry.Path)
+		if err != nil || current.ETag != entry.ETag {
+			return err
+		}
+		current.ValidatedAt = time.Now()
+		return cc.writeEntry(current)
+	})
+}
+
+// invalidate removes the cached copy of a path
+func (cc *CachedClient) invalidate(path string) {
+	if !isS3Path(path) {
+		return
+	}
+	cc.withLock(func() error {
+		entry, err := cc.readEntry(path)
+		if err != nil {
+			return err
+		}
+		os.Remove(cc.entryPath(path))
+		return os.Remove(cc.dataPath(path, entry.ETag))
+	})
+}
+
+func (cc *CachedClient) readEntry(path string) (cacheEntry, error) {
+	body, err := os.ReadFile(cc.entryPath(path))
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	var entry cacheEntry
+	err = json.Unmarshal(body, &entry)
+	return entry, err
+}
+
+func (cc *CachedClient) writeEntry(entry cacheEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeToLocalFile(cc.entryPath(entry.Path), bytes.NewReader(body), cc.fileMode(), cc.dirMode())
+}
+
+// withLock runs fn while holding the cache directory lock
+func (cc *CachedClient) withLock(fn func() error) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	lock, err := os.OpenFile(filepath.Join(cc.opts.Dir, cacheLockFile), os.O_CREATE|os.O_RDWR, cc.fileMode())
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+	return fn()
+}
+
+// evict removes the least recently used data files until the cache is below MaxBytes. The
+// caller must hold the cache lock.
+func (cc *CachedClient) evict() error {
+	if cc.opts.MaxBytes <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(cc.opts.Dir)
+	if err != nil {
+		return err
+	}
+	var files []os.FileInfo
+	var total int64
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), cacheDataExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, info := range files {
+		if total <= cc.opts.MaxBytes {
+			break
+		}
+		dataPath := filepath.Join(cc.opts.Dir, info.Name())
+		if err := os.Remove(dataPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		entryPath := filepath.Join(cc.opts.Dir, strings.SplitN(info.Name(), "-", 2)[0]+cacheEntryExt)
+		if body, err := os.ReadFile(entryPath); err == nil {
+			var entry cacheEntry
+			if json.Unmarshal(body, &entry) == nil && cc.dataPath(entry.Path, entry.ETag) == dataPath {
+				os.Remove(entryPath)
+			}
+		}
+		total -= info.Size()
+		cc.evictions.Add(1)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package pathio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingS3Handler counts the GetObject and HeadObject requests it serves
+type countingS3Handler struct {
+	*fakeS3Handler
+	mu    sync.Mutex
+	gets  int
+	heads int
+}
+
+func (h *countingS3Handler) GetObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	h.mu.Lock()
+	h.gets++
+	h.mu.Unlock()
+	return h.fakeS3Handler.GetObject(ctx, input)
+}
+
+func (h *countingS3Handler) HeadObject(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	h.mu.Lock()
+	h.heads++
+	h.mu.Unlock()
+	return h.fakeS3Handler.HeadObject(ctx, input)
+}
+
+func (h *countingS3Handler) requests() (gets, heads int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.gets, h.heads
+}
+
+// closeTrackingBody is a response body that fails reads once it is closed
+type closeTrackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeTrackingBody) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, errors.New("read from a closed body")
+	}
+	return b.Reader.Read(p)
+}
+
+func (b *closeTrackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func newTestCachedClient(t *testing.T, handler s3Handler, opts CacheOptions) *CachedClient {
+	if opts.Dir == "" {
+		opts.Dir = t.TempDir()
+	}
+	cc, err := NewCachedClient(&Client{ctx: context.Background(), handler: handler}, opts)
+	require.NoError(t, err)
+	return cc
+}
+
+func readCached(t *testing.T, cc *CachedClient, path string) string {
+	rc, err := cc.Reader(path)
+	require.NoError(t, err)
+	defer rc.Close()
+	body, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestCachedClientReader(t *testing.T) {
+	tests := []struct {
+		desc         string
+		opts         CacheOptions
+		wantGets     int
+		wantHeads    int
+		wantRevalids int64
+		// wantStale is whether the read after the object changed still returns the cached body
+		wantStale bool
+	}{
+		{
+			desc: "revalidate with If-None-Match",
+			opts: CacheOptions{Revalidation: RevalidateIfNoneMatch},
+			// miss, not modified, changed
+			wantGets:     3,
+			wantRevalids: 2,
+		},
+		{
+			desc: "revalidate with HeadObject",
+			opts: CacheOptions{Revalidation: RevalidateHead},
+			// miss, then a head for each revalidation and a get once it changed
+			wantGets:     2,
+			wantHeads:    2,
+			wantRevalids: 2,
+		},
+		{
+			desc:      "fresh within max age",
+			opts:      CacheOptions{Revalidation: RevalidateHead, MaxAge: time.Hour},
+			wantGets:  1,
+			wantStale: true,
+		},
+		{
+			desc:      "never revalidate",
+			opts:      CacheOptions{Revalidation: RevalidateNever},
+			wantGets:  1,
+			wantStale: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			handler := &countingS3Handler{fakeS3Handler: newFakeS3Handler()}
+			handler.put("bucket", "key", "version 1")
+			cc := newTestCachedClient(t, handler, test.opts)
+
+			assert.Equal(t, "version 1", readCached(t, cc, "s3://bucket/key"))
+			assert.Equal(t, "version 1", readCached(t, cc, "s3://bucket/key"))
+			handler.put("bucket", "key", "version 2")
+			if test.wantStale {
+				assert.Equal(t, "version 1", readCached(t, cc, "s3://bucket/key"))
+			} else {
+				assert.Equal(t, "version 2", readCached(t, cc, "s3://bucket/key"))
+			}
+
+			gets, heads := handler.requests()
+			assert.Equal(t, test.wantGets, gets)
+			assert.Equal(t, test.wantHeads, heads)
+			stats := cc.Stats()
+			assert.Equal(t, test.wantRevalids, stats.Revalidations)
+			assert.Equal(t, int64(3), stats.Hits+stats.Misses)
+		})
+	}
+}
+
+func TestCachedClientEviction(t *testing.T) {
+	handler := &countingS3Handler{fakeS3Handler: newFakeS3Handler()}
+	for _, key := range []string{"a", "b", "c"} {
+		handler.put("bucket", key, "0123456789")
+	}
+	cc := newTestCachedClient(t, handler, CacheOptions{MaxBytes: 25, Revalidation: RevalidateNever})
+
+	readCached(t, cc, "s3://bucket/a")
+	readCached(t, cc, "s3://bucket/b")
+	// Reading a again makes b the least recently used
+	time.Sleep(10 * time.Millisecond)
+	readCached(t, cc, "s3://bucket/a")
+	time.Sleep(10 * time.Millisecond)
+	readCached(t, cc, "s3://bucket/c")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3, Evictions: 1}, cc.Stats())
+
+	readCached(t, cc, "s3://bucket/a")
+	readCached(t, cc, "s3://bucket/b")
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 4, Evictions: 2}, cc.Stats())
+}
+
+func TestCachedClientInvalidation(t *testing.T) {
+	handler := &countingS3Handler{fakeS3Handler: newFakeS3Handler()}
+	handler.put("bucket", "key", "old")
+	cc := newTestCachedClient(t, handler, CacheOptions{Revalidation: RevalidateNever})
+
+	assert.Equal(t, "old", readCached(t, cc, "s3://bucket/key"))
+	require.NoError(t, cc.Write("s3://bucket/key", []byte("new")))
+	assert.Equal(t, "new", readCached(t, cc, "s3://bucket/key"))
+
+	info, err := cc.Stat("s3://bucket/key")
+	require.NoError(t, err)
+	require.NoError(t, cc.WriteIfMatch("s3://bucket/key", []byte("newer"), info.ETag))
+	assert.Equal(t, "newer", readCached(t, cc, "s3://bucket/key"))
+	require.NoError(t, cc.WriteWithOptions("s3://bucket/key", []byte("newest"), WriteOptions{}))
+	assert.Equal(t, "newest", readCached(t, cc, "s3://bucket/key"))
+
+	handler.put("bucket", "src/key", "synced")
+	handler.put("bucket", "dst/key", "old")
+	assert.Equal(t, "old", readCached(t, cc, "s3://bucket/dst/key"))
+	_, err = cc.Sync("s3://bucket/src", "s3://bucket/dst", SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "synced", readCached(t, cc, "s3://bucket/dst/key"))
+
+	require.NoError(t, cc.Delete("s3://bucket/key"))
+	_, err = cc.Reader("s3://bucket/key")
+	assert.True(t, isNotExist(err))
+}
+
+func TestCachedClientFillWithoutETag(t *testing.T) {
+	cc := newTestCachedClient(t, newFakeS3Handler(), CacheOptions{})
+	body := &closeTrackingBody{Reader: strings.NewReader("uncached")}
+	rc, err := cc.fill("s3://bucket/key", body, "")
+	require.NoError(t, err)
+	read, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "uncached", string(read))
+	require.NoError(t, rc.Close())
+	assert.True(t, body.closed)
+}
+
+func TestCachedClientSharedDir(t *testing.T) {
+	handler := &countingS3Handler{fakeS3Handler: newFakeS3Handler()}
+	handler.put("bucket", "key", "shared")
+	dir := t.TempDir()
+	first := newTestCachedClient(t, handler, CacheOptions{Dir: dir, Revalidation: RevalidateNever})
+	second := newTestCachedClient(t, handler, CacheOptions{Dir: dir, Revalidation: RevalidateNever})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); assert.Equal(t, "shared", readCached(t, first, "s3://bucket/key")) }()
+		go func() { defer wg.Done(); assert.Equal(t, "shared", readCached(t, second, "s3://bucket/key")) }()
+	}
+	wg.Wait()
+
+	// A client started later finds the object in the shared directory
+	gets, _ := handler.requests()
+	third := newTestCachedClient(t, handler, CacheOptions{Dir: dir, Revalidation: RevalidateNever})
+	assert.Equal(t, "shared", readCached(t, third, "s3://bucket/key"))
+	assert.Equal(t, CacheStats{Hits: 1}, third.Stats())
+	after, _ := handler.requests()
+	assert.Equal(t, gets, after)
+}
+
+func TestNewCachedClientRequiresDir(t *testing.T) {
+	_, err := NewCachedClient(&Client{}, CacheOptions{})
+	assert.Error(t, err)
+}
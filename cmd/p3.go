@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"log"
+	"mime"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,6 +20,7 @@ import (
 
 var (
 	awsProfile = kingpin.Flag("profile", "AWS profile to use in lieu of the AWS_SECRET_ACCESS_KEY and AWS_ACCESS_KEY_ID environment variables").Default("").String()
+	dryRun     = kingpin.Flag("dry-run", "print the writes, deletes and copies the command would do without doing them").Bool()
 
 	listCommand = kingpin.Command("list", "list contents of an S3 path")
 	listPath    = listCommand.Arg("file_path", "S3 or local path to list the contents").Required().String()
@@ -24,10 +28,14 @@ var (
 	downloadCommand   = kingpin.Command("download", "download contents of an S3 path to a local file")
 	downloadS3Path    = downloadCommand.Arg("s3_path", "S3 path to download").Required().String()
 	downloadLocalPath = downloadCommand.Arg("local_path", "local file to write to").Required().String()
+	downloadPartSize  = downloadCommand.Flag("part-size", "size of the concurrent ranged GETs used for S3 paths").Default("5MiB").Bytes()
+	downloadWorkers   = downloadCommand.Flag("concurrency", "number of parts to download at once").Default("5").Int()
 
 	uploadCommand   = kingpin.Command("upload", "upload contents of a local file to an S3 path")
 	uploadS3Path    = uploadCommand.Arg("s3_path", "S3 path to upload").Required().String()
 	uploadLocalPath = uploadCommand.Arg("local_path", "local file to write to").Required().String()
+	uploadResumable = uploadCommand.Flag("resumable", "upload in parts and resume an interrupted upload of the same file").Bool()
+	uploadStateDir  = uploadCommand.Flag("state-dir", "directory for the state of resumable uploads (default: $XDG_CACHE_HOME/p3/uploads)").String()
 
 	deleteCommand = kingpin.Command("delete", "delete contents of an S3 path")
 	deletePath    = deleteCommand.Arg("file_path", "S3 path or local file path to delete").Required().String()
@@ -39,8 +47,26 @@ var (
 	contents     = writeCommand.Arg("contents", "string to write to a file").Required().String()
 	toPath       = writeCommand.Arg("destination_path", "the local file path or S3 path to be written to").Required().String()
 
+	syncCommand     = kingpin.Command("sync", "copy the files that differ from a source directory or S3 prefix to a destination")
+	syncSrc         = syncCommand.Arg("src", "S3 prefix or local directory to copy from").Required().String()
+	syncDst         = syncCommand.Arg("dst", "S3 prefix or local directory to copy to").Required().String()
+	syncDelete      = syncCommand.Flag("delete", "delete destination files that don't exist in the source").Bool()
+	syncInclude     = syncCommand.Flag("include", "only sync files matching the glob (repeatable)").Strings()
+	syncExclude     = syncCommand.Flag("exclude", "skip files matching the glob (repeatable)").Strings()
+	syncChecksum    = syncCommand.Flag("checksum", "compare files by size and MD5 instead of size and modification time").Bool()
+	syncConcurrency = syncCommand.Flag("concurrency", "number of files to copy at once").Default("8").Int()
+
+	diffCommand = kingpin.Command("diff", "list the files added, removed and changed between two directories or S3 prefixes")
+	diffA       = diffCommand.Arg("a", "S3 prefix or local directory to compare from").Required().String()
+	diffB       = diffCommand.Arg("b", "S3 prefix or local directory to compare to").Required().String()
+	diffJSON    = diffCommand.Flag("json", "print the differences as JSON").Bool()
+
 	presignedURLCommand = kingpin.Command("presigned-url", "generate a presigned URL for an S3 path")
 	presignedURLPath    = presignedURLCommand.Arg("path", "S3 path to generate a presigned URL for").Required().String()
+	presignedURLMethod  = presignedURLCommand.Flag("method", "HTTP method the URL is for: GET to download or PUT to upload").Default("GET").Enum("GET", "PUT")
+	presignedURLType    = presignedURLCommand.Flag("content-type", "content type the upload must have, for PUT URLs").String()
+	presignedURLExpires = presignedURLCommand.Flag("expires", "how long the URL is valid").Default("1h").Duration()
+	presignedURLName    = presignedURLCommand.Flag("filename", "file name browsers save the download as, for GET URLs").String()
 )
 
 func newPathioClientWithS3() *pathio.Client {
@@ -56,6 +82,29 @@ func newPathioClientWithS3() *pathio.Client {
 	return pathio.NewClient(ctx, &cfg)
 }
 
+// newClient returns a new client for a command on the paths, with an AWS config if one of them
+// is an S3 path. With --dry-run, the client prints the writes, deletes and copies it would do
+// instead of doing them.
+func newClient(paths ...string) *pathio.Client {
+	client := pathio.NewClient(context.Background(), nil)
+	for _, path := range paths {
+		if isS3Path(path) {
+			client = newPathioClientWithS3()
+			break
+		}
+	}
+	if *dryRun {
+		client.DryRun = &pathio.DryRunJournal{Log: os.Stdout}
+	}
+	return client
+}
+
+// isS3Path reports whether the path is an S3 path, which needs an AWS config. The scheme is
+// checked without Parse, so paths in legacy buckets that Parse rejects still get one.
+func isS3Path(path string) bool {
+	return len(path) >= len("s3://") && strings.EqualFold(path[:len("s3://")], "s3://")
+}
+
 func main() {
 	command := kingpin.Parse()
 
@@ -78,6 +127,12 @@ func main() {
 	// Pathio's Write
 	case writeCommand.FullCommand():
 		writeCommandFn()
+	// Pathio's Sync
+	case syncCommand.FullCommand():
+		syncCommandFn()
+	// Pathio's Diff
+	case diffCommand.FullCommand():
+		diffCommandFn()
 	// Pathio's GeneratePresignedURL
 	case presignedURLCommand.FullCommand():
 		presignedURLCommandFn()
@@ -87,12 +142,7 @@ func main() {
 }
 
 func listCommandFn() {
-	var client pathio.Pathio
-	if strings.HasPrefix(*listPath, "s3://") {
-		client = newPathioClientWithS3()
-	} else {
-		client = pathio.DefaultClient
-	}
+	client := newClient(*listPath)
 
 	results, err := client.ListFiles(*listPath)
 	if err != nil {
@@ -104,6 +154,10 @@ func listCommandFn() {
 }
 
 func downloadCommandFn() {
+	if *dryRun {
+		fmt.Printf("(dry run) download %s to %s\n", *downloadS3Path, *downloadLocalPath)
+		return
+	}
 	client := newPathioClientWithS3()
 
 	file, err := os.Create(*downloadLocalPath)
@@ -111,12 +165,10 @@ func downloadCommandFn() {
 		log.Fatalf("Error creating local file: %s", err)
 	}
 	defer file.Close()
-	reader, err := client.Reader(*downloadS3Path)
-	if err != nil {
-		log.Fatalf("Failed to find s3 file: %s", err)
-	}
-	defer reader.Close()
-	_, err = io.Copy(file, reader)
+	client.DownloadPartSize = int64(*downloadPartSize)
+	client.DownloadConcurrency = *downloadWorkers
+	client.Progress = newProgressBar(os.Stdout)
+	_, err = client.Download(*downloadS3Path, file)
 	if err != nil {
 		log.Fatalf("Failed to download and write s3 file: %s", err)
 	}
@@ -124,42 +176,49 @@ func downloadCommandFn() {
 }
 
 func uploadCommandFn() {
-	client := newPathioClientWithS3()
+	client := newClient(*uploadS3Path)
 
 	file, err := os.Open(*uploadLocalPath)
 	if err != nil {
 		log.Fatalf("Error opening file to upload: %s", err)
 	}
 	defer file.Close()
+	client.Progress = newProgressBar(os.Stdout)
+	if *uploadResumable {
+		client.ResumableUploadDir = *uploadStateDir
+		if client.ResumableUploadDir == "" {
+			cacheDir, err := os.UserCacheDir()
+			if err != nil {
+				log.Fatalf("Error finding a directory for the upload state, use --state-dir: %s", err)
+			}
+			client.ResumableUploadDir = filepath.Join(cacheDir, "p3", "uploads")
+		}
+	}
 	err = client.WriteReader(*uploadS3Path, file)
 	if err != nil {
 		log.Fatalf("Error uploading file: %s", err)
 	}
+	if *dryRun {
+		return
+	}
 	fmt.Printf("Uploaded %s to %s\n", *uploadLocalPath, *uploadS3Path)
 }
 
 func deleteCommandFn() {
-	var client pathio.Pathio
-	if strings.HasPrefix(*deletePath, "s3://") {
-		client = newPathioClientWithS3()
-	} else {
-		client = pathio.DefaultClient
-	}
+	client := newClient(*deletePath)
 
 	err := client.Delete(*deletePath)
 	if err != nil {
 		log.Fatalf("error deleting file: %s", err)
 	}
+	if *dryRun {
+		return
+	}
 	fmt.Printf("Deleted %s successfully\n", *deletePath)
 }
 
 func existsCommandFn() {
-	var client pathio.Pathio
-	if strings.HasPrefix(*existsPath, "s3://") {
-		client = newPathioClientWithS3()
-	} else {
-		client = pathio.DefaultClient
-	}
+	client := newClient(*existsPath)
 
 	exists, err := client.Exists(*existsPath)
 	if err != nil {
@@ -173,26 +232,135 @@ func existsCommandFn() {
 }
 
 func writeCommandFn() {
-	var client pathio.Pathio
-	if strings.HasPrefix(*toPath, "s3://") {
-		client = newPathioClientWithS3()
-	} else {
-		client = pathio.DefaultClient
-	}
+	client := newClient(*toPath)
 
 	err := client.Write(*toPath, []byte(*contents))
 	if err != nil {
 		log.Fatalf("error checking if file exists: %s", err)
 	}
+	if *dryRun {
+		return
+	}
 	fmt.Printf("Wrote contents to: %s\n", *toPath)
 }
 
+func syncCommandFn() {
+	client := newClient(*syncSrc, *syncDst)
+
+	opts := pathio.SyncOptions{
+		Delete:      *syncDelete,
+		Include:     *syncInclude,
+		Exclude:     *syncExclude,
+		Concurrency: *syncConcurrency,
+	}
+	if *syncChecksum {
+		opts.Compare = pathio.SyncChecksum
+	}
+	// With --dry-run, the client prints the copies and deletes as they are planned
+	result, err := client.Sync(*syncSrc, *syncDst, opts)
+	if result != nil && !*dryRun {
+		for _, rel := range result.Copied {
+			fmt.Printf("copy %s/%s to %s/%s\n", strings.TrimSuffix(*syncSrc, "/"), rel, strings.TrimSuffix(*syncDst, "/"), rel)
+		}
+		for _, rel := range result.Deleted {
+			fmt.Printf("delete %s/%s\n", strings.TrimSuffix(*syncDst, "/"), rel)
+		}
+	}
+	if err != nil {
+		log.Fatalf("error syncing %s to %s: %s", *syncSrc, *syncDst, err)
+	}
+	fmt.Printf("%d copied, %d deleted, %d unchanged\n", len(result.Copied), len(result.Deleted), result.Unchanged)
+}
+
+// diffFile is the JSON form of one side of a diff entry
+type diffFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	ETag    string    `json:"etag,omitempty"`
+}
+
+// diffEntry is the JSON form of a pathio.DiffEntry
+type diffEntry struct {
+	Path string    `json:"path"`
+	A    *diffFile `json:"a,omitempty"`
+	B    *diffFile `json:"b,omitempty"`
+}
+
+func newDiffEntries(entries []pathio.DiffEntry) []diffEntry {
+	converted := []diffEntry{}
+	for _, entry := range entries {
+		e := diffEntry{Path: entry.Path}
+		if entry.A != nil {
+			e.A = &diffFile{entry.A.Size, entry.A.ModTime, entry.A.ETag}
+		}
+		if entry.B != nil {
+			e.B = &diffFile{entry.B.Size, entry.B.ModTime, entry.B.ETag}
+		}
+		converted = append(converted, e)
+	}
+	return converted
+}
+
+func diffCommandFn() {
+	client := newClient(*diffA, *diffB)
+
+	result, err := client.Diff(*diffA, *diffB)
+	if err != nil {
+		log.Fatalf("error comparing %s to %s: %s", *diffA, *diffB, err)
+	}
+	if *diffJSON {
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		err := out.Encode(map[string][]diffEntry{
+			"added":   newDiffEntries(result.Added),
+			"removed": newDiffEntries(result.Removed),
+			"changed": newDiffEntries(result.Changed),
+		})
+		if err != nil {
+			log.Fatalf("error writing JSON: %s", err)
+		}
+		return
+	}
+	for _, entry := range result.Added {
+		fmt.Printf("+ %s (%s)\n", entry.Path, formatBytes(float64(entry.B.Size)))
+	}
+	for _, entry := range result.Removed {
+		fmt.Printf("- %s (%s)\n", entry.Path, formatBytes(float64(entry.A.Size)))
+	}
+	for _, entry := range result.Changed {
+		fmt.Printf("~ %s (%s -> %s, modified %s -> %s)\n", entry.Path,
+			formatBytes(float64(entry.A.Size)), formatBytes(float64(entry.B.Size)),
+			entry.A.ModTime.Format(time.RFC3339), entry.B.ModTime.Format(time.RFC3339))
+	}
+	fmt.Printf("%d added, %d removed, %d changed\n", len(result.Added), len(result.Removed), len(result.Changed))
+}
+
 func presignedURLCommandFn() {
 	client := newPathioClientWithS3()
 
-	presignedURL, err := client.GeneratePresignedURL(*presignedURLPath, 1*time.Hour)
+	var req *pathio.PresignedRequest
+	var err error
+	if *presignedURLMethod == "PUT" {
+		req, err = client.GeneratePresignedPutURL(*presignedURLPath, *presignedURLExpires, pathio.PresignPutOptions{
+			ContentType: *presignedURLType,
+		})
+	} else {
+		opts := pathio.PresignGetOptions{Expiration: *presignedURLExpires}
+		if *presignedURLName != "" {
+			opts.ResponseContentDisposition = mime.FormatMediaType("attachment", map[string]string{"filename": *presignedURLName})
+		}
+		req, err = client.GeneratePresignedGetURL(*presignedURLPath, opts)
+	}
 	if err != nil {
 		log.Fatalf("error generating presigned URL: %s", err)
 	}
-	fmt.Printf("Presigned URL: %s\n", presignedURL)
+	fmt.Printf("Presigned URL: %s\n", req.URL)
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("Required header: %s: %s\n", name, req.Header.Get(name))
+	}
 }
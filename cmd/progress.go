@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Clever/pathio/v5"
+)
+
+const progressBarWidth = 30
+
+// newProgressBar returns a ProgressFunc that draws a progress bar with the throughput and ETA
+// of a transfer, or nil if out isn't a terminal
+func newProgressBar(out *os.File) pathio.ProgressFunc {
+	info, err := out.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	start := time.Now()
+	return func(done, total int64) {
+		drawProgressBar(out, done, total, time.Since(start))
+	}
+}
+
+// drawProgressBar redraws the progress line, ending it once the transfer is complete
+func drawProgressBar(w io.Writer, done, total int64, elapsed time.Duration) {
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(done) / elapsed.Seconds()
+	}
+	if total <= 0 {
+		fmt.Fprintf(w, "\r%s  %s/s ", formatBytes(float64(done)), formatBytes(rate))
+		return
+	}
+
+	filled := int(float64(progressBarWidth) * float64(done) / float64(total))
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	eta := "--"
+	if rate > 0 {
+		eta = time.Duration(float64(total-done) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	fmt.Fprintf(w, "\r[%s] %3.0f%%  %s / %s  %s/s  ETA %s ", bar, 100*float64(done)/float64(total),
+		formatBytes(float64(done)), formatBytes(float64(total)), formatBytes(rate), eta)
+	if done >= total {
+		fmt.Fprintln(w)
+	}
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
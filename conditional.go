@@ -0,0 +1,379 @@
+package pathio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	// ErrPreconditionFailed is returned by conditional writes whose condition doesn't hold: the
+	// object already exists for WriteIfAbsent, or is missing or has a different ETag for
+	// WriteIfMatch.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrNotModified is returned by ReaderIfNoneMatch when the object still has the given ETag.
+	ErrNotModified = errors.New("not modified")
+)
+
+// localConditionalMu serializes local conditional writes within the process, on top of the
+// directory lock that serializes them across processes
+var localConditionalMu sync.Mutex
+
+// WriteIfAbsent writes a byte array to the specified path only if nothing exists there yet,
+// and otherwise returns an error wrapping ErrPreconditionFailed. The path can be either a
+// local file path or an S3 path. S3 paths use PutObject with If-None-Match: *.
+func (c *Client) WriteIfAbsent(path string, input []byte) error {
+	return c.conditionalWrite(path, input, func() error { return c.writeIfAbsent(path, input) })
+}
+
+func (c *Client) writeIfAbsent(path string, input []byte) error {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return err
+		}
+		return writeToS3Conditional(c.ctx, s3Conn, bytes.NewReader(input), c.disableS3Encryption, "", "*")
+	}
+	if isRemoteOnlyPath(path) {
+		return errConditionalUnsupported(path)
+	}
+	if err := writeToLocalFileIfAbsent(path, c.limitWriter(bytes.NewReader(input), path), c.fileMode(), c.dirMode()); err != nil {
+		return err
+	}
+	return removeLocalSidecar(path)
+}
+
+// WriteIfMatch writes a byte array to the specified path only if the object there still has
+// the given ETag, as returned by Stat, and otherwise returns an error wrapping
+// ErrPreconditionFailed. The path can be either a local file path or an S3 path. S3 paths use
+// PutObject with If-Match.
+func (c *Client) WriteIfMatch(path string, input []byte, etag string) error {
+	return c.conditionalWrite(path, input, func() error { return c.writeIfMatch(path, input, etag) })
+}
+
+// conditionalWrite runs a conditional write of input to the path through the dry-run journal
+// and the audit log, like WriteReader
+func (c *Client) conditionalWrite(path string, input []byte, write func() error) error {
+	if c.DryRun != nil {
+		c.DryRun.record(DryRunEntry{Op: "write", Path: path, Size: int64(len(input))})
+		return nil
+	}
+	return c.auditedWrite(path, input, write)
+}
+
+func (c *Client) writeIfMatch(path string, input []byte, etag string) error {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return err
+		}
+		return writeToS3Conditional(c.ctx, s3Conn, bytes.NewReader(input), c.disableS3Encryption, etag, "")
+	}
+	if isRemoteOnlyPath(path) {
+		return errConditionalUnsupported(path)
+	}
+	if err := writeToLocalFileIfMatch(path, c.limitWriter(bytes.NewReader(input), path), etag, c.fileMode(), c.dirMode()); err != nil {
+		return err
+	}
+	return removeLocalSidecar(path)
+}
+
+// ReaderIfNoneMatch returns an io.ReadCloser for the specified path and its current ETag,
+// unless the object still has the given ETag, in which case it returns an error wrapping
+// ErrNotModified. The path can be either a local file path or an S3 path. Reads are rate limited
+// and report progress as with Reader. It is the caller's responsibility to close rc.
+func (c *Client) ReaderIfNoneMatch(path, etag string) (rc io.ReadCloser, currentETag string, err error) {
+	rc, currentETag, err = c.readerIfNoneMatch(path, etag)
+	if err != nil || c.Progress == nil {
+		return rc, currentETag, err
+	}
+	total := int64(-1)
+	if info, err := c.Stat(path); err == nil && !info.IsDir {
+		total = info.Size
+	}
+	return progressReadCloser{rc, c.newProgress(total)}, currentETag, nil
+}
+
+// readerIfNoneMatch implements ReaderIfNoneMatch without progress reporting
+func (c *Client) readerIfNoneMatch(path, etag string) (io.ReadCloser, string, error) {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return nil, "", err
+		}
+		return s3FileReaderIfNoneMatch(c.ctx, s3Conn, etag)
+	}
+	if isRemoteOnlyPath(path) {
+		return nil, "", errConditionalUnsupported(path)
+	}
+	rc, currentETag, err := localFileReaderIfNoneMatch(path, etag)
+	if err != nil {
+		return nil, "", err
+	}
+	return c.limitReader(rc, path), currentETag, nil
+}
+
+// deleteIfMatch deletes the object at the specified path only if it still has the given ETag,
+// and otherwise returns an error wrapping ErrPreconditionFailed
+func (c *Client) deleteIfMatch(path, etag string) error {
+	return c.audited(AuditRecord{Op: "delete", Path: path}, func() error {
+		return c.deleteObjectIfMatch(path, etag)
+	})
+}
+
+func (c *Client) deleteObjectIfMatch(path, etag string) error {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return err
+		}
+		_, err = s3Conn.handler.DeleteObject(c.ctx, &s3.DeleteObjectInput{
+			Bucket:  aws.String(s3Conn.bucket),
+			Key:     aws.String(s3Conn.key),
+			IfMatch: aws.String(etag),
+		})
+		if isS3PreconditionFailed(err) {
+			return fmt.Errorf("%w: %s", ErrPreconditionFailed, s3Conn.path())
+		}
+		if err != nil {
+			return s3DeleteError(c.ctx, s3Conn, err)
+		}
+		return nil
+	}
+	if isRemoteOnlyPath(path) {
+		return errConditionalUnsupported(path)
+	}
+	return deleteLocalFileIfMatch(path, etag, c.dirMode())
+}
+
+// isRemoteOnlyPath reports whether the path is neither local nor S3
+func isRemoteOnlyPath(path string) bool {
+	return isHTTPPath(path) || isSFTPPath(path) || isArchivePath(path)
+}
+
+func errConditionalUnsupported(path string) error {
+	return fmt.Errorf("%w: conditional requests are only supported for local and s3 paths, got: %s", errors.ErrUnsupported, path)
+}
+
+// newS3PutObjectInput returns the PutObject parameters pathio uses to upload input
+func newS3PutObjectInput(s3Conn s3Connection, input io.Reader, disableEncryption bool) *s3.PutObjectInput {
+	params := &s3.PutObjectInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Key:    aws.String(s3Conn.key),
+		Body:   input,
+	}
+	if !disableEncryption {
+		params.ServerSideEncryption = aesAlgo
+	}
+	return params
+}
+
+// writeToS3Conditional uploads the given file to S3 with an If-Match or If-None-Match condition
+func writeToS3Conditional(ctx context.Context, s3Conn s3Connection, input io.ReadSeeker, disableEncryption bool, ifMatch, ifNoneMatch string) error {
+	params := newS3PutObjectInput(s3Conn, input, disableEncryption)
+	if ifMatch != "" {
+		params.IfMatch = aws.String(ifMatch)
+	}
+	if ifNoneMatch != "" {
+		params.IfNoneMatch = aws.String(ifNoneMatch)
+	}
+	_, err := s3Conn.handler.PutObject(ctx, params)
+	if isS3PreconditionFailed(err) {
+		return fmt.Errorf("%w: %s", ErrPreconditionFailed, s3Conn.path())
+	}
+	return err
+}
+
+// s3FileReaderIfNoneMatch converts an S3Path into an io.ReadCloser unless its ETag matches
+func s3FileReaderIfNoneMatch(ctx context.Context, s3Conn s3Connection, etag string) (io.ReadCloser, string, error) {
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Key:    aws.String(s3Conn.key),
+	}
+	if etag != "" {
+		params.IfNoneMatch = aws.String(etag)
+	}
+	resp, err := s3Conn.handler.GetObject(ctx, params)
+	if err != nil {
+		if s3StatusCode(err) == http.StatusNotModified || s3ErrorCode(err) == "NotModified" {
+			return nil, "", fmt.Errorf("%w: %s", ErrNotModified, s3Conn.path())
+		}
+		return nil, "", s3ReadError(s3Conn, err)
+	}
+	return resp.Body, aws.ToString(resp.ETag), nil
+}
+
+// isS3PreconditionFailed reports whether a conditional S3 request failed because of its
+// condition. A concurrent conditional write to the same key is reported as a conflict, which
+// callers handle the same way.
+func isS3PreconditionFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+	var noSuchKey *s3Types.NoSuchKey
+	switch {
+	case errors.As(err, &noSuchKey):
+		return true
+	case s3ErrorCode(err) == "PreconditionFailed", s3ErrorCode(err) == "ConditionalRequestConflict":
+		return true
+	}
+	status := s3StatusCode(err)
+	return status == http.StatusPreconditionFailed || status == http.StatusConflict
+}
+
+// s3ErrorCode returns the API error code of an S3 error, or "" if there is none
+func s3ErrorCode(err error) string {
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) {
+		return apiError.ErrorCode()
+	}
+	return ""
+}
+
+// s3StatusCode returns the HTTP status code of an S3 error, or 0 if there is none
+func s3StatusCode(err error) int {
+	var respError *awshttp.ResponseError
+	if errors.As(err, &respError) {
+		return respError.HTTPStatusCode()
+	}
+	return 0
+}
+
+// localETag returns the ETag of a local file, built from its inode, modification time and
+// size. It changes whenever the file is rewritten, since local writes replace the file.
+func localETag(info os.FileInfo) string {
+	if !info.Mode().IsRegular() {
+		return ""
+	}
+	return fmt.Sprintf(`"%x-%x-%x"`, fileID(info), info.ModTime().UnixNano(), info.Size())
+}
+
+// withLocalDirLock runs fn while holding the lock on the directory of path, creating the
+// directory if needed
+func withLocalDirLock(path string, dirMode os.FileMode, fn func() error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return err
+	}
+	localConditionalMu.Lock()
+	defer localConditionalMu.Unlock()
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := lockFile(d); err != nil {
+		return err
+	}
+	defer unlockFile(d)
+	return fn()
+}
+
+// writeToLocalFileIfAbsent writes the given file locally if it doesn't exist. The input is
+// written to a temporary file which is hard linked into place, which fails if the destination
+// exists just like O_EXCL, without exposing a partially written file. File systems without
+// hard links fall back to creating the destination with O_EXCL.
+func writeToLocalFileIfAbsent(path string, input io.Reader, fileMode, dirMode os.FileMode) error {
+	return withLocalDirLock(path, dirMode, func() error {
+		if _, err := os.Lstat(path); err == nil {
+			return fmt.Errorf("%w: %s already exists", ErrPreconditionFailed, path)
+		}
+		tmpPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp-link")
+		if err := writeToLocalFile(tmpPath, input, fileMode, dirMode); err != nil {
+			return err
+		}
+		defer os.Remove(tmpPath)
+		err := os.Link(tmpPath, path)
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%w: %s already exists", ErrPreconditionFailed, path)
+		}
+		if err == nil {
+			return nil
+		}
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fileMode)
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%w: %s already exists", ErrPreconditionFailed, path)
+		} else if err != nil {
+			return err
+		}
+		tmp, err := os.Open(tmpPath)
+		if err == nil {
+			_, err = io.Copy(file, tmp)
+			tmp.Close()
+		}
+		if err == nil {
+			err = file.Sync()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+		return err
+	})
+}
+
+// writeToLocalFileIfMatch replaces the given file locally if its ETag matches
+func writeToLocalFileIfMatch(path string, input io.Reader, etag string, fileMode, dirMode os.FileMode) error {
+	return withLocalDirLock(path, dirMode, func() error {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s does not exist", ErrPreconditionFailed, path)
+		} else if err != nil {
+			return err
+		}
+		if current := localETag(info); current != etag {
+			return fmt.Errorf("%w: %s has ETag %s, not %s", ErrPreconditionFailed, path, current, etag)
+		}
+		return writeToLocalFile(path, input, fileMode, dirMode)
+	})
+}
+
+// deleteLocalFileIfMatch removes the given file locally if its ETag matches
+func deleteLocalFileIfMatch(path, etag string, dirMode os.FileMode) error {
+	return withLocalDirLock(path, dirMode, func() error {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s does not exist", ErrPreconditionFailed, path)
+		} else if err != nil {
+			return err
+		}
+		if current := localETag(info); current != etag {
+			return fmt.Errorf("%w: %s has ETag %s, not %s", ErrPreconditionFailed, path, current, etag)
+		}
+		return os.Remove(path)
+	})
+}
+
+// localFileReaderIfNoneMatch opens the given file unless its ETag matches
+func localFileReaderIfNoneMatch(path, etag string) (io.ReadCloser, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	current := localETag(info)
+	if current == etag {
+		file.Close()
+		return nil, "", fmt.Errorf("%w: %s", ErrNotModified, path)
+	}
+	return file, current, nil
+}
@@ -0,0 +1,168 @@
+package pathio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalWrites(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		client *Client
+		path   string
+	}{
+		{
+			desc:   "Local",
+			client: &Client{ctx: context.Background()},
+			path:   filepath.Join(t.TempDir(), "dir", "lock.json"),
+		},
+		{
+			desc:   "S3",
+			client: newFakeS3Client(newFakeS3Handler()),
+			path:   "s3://bucket/locks/lock.json",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := tc.client
+			err := c.WriteIfMatch(tc.path, []byte("v0"), `"missing"`)
+			assert.True(t, errors.Is(err, ErrPreconditionFailed), "got %v", err)
+
+			require.NoError(t, c.WriteIfAbsent(tc.path, []byte("v1")))
+			err = c.WriteIfAbsent(tc.path, []byte("v2"))
+			assert.True(t, errors.Is(err, ErrPreconditionFailed), "got %v", err)
+
+			info, err := c.Stat(tc.path)
+			require.NoError(t, err)
+			assert.NotEmpty(t, info.ETag)
+
+			rc, etag, err := c.ReaderIfNoneMatch(tc.path, `"other"`)
+			require.NoError(t, err)
+			body, err := io.ReadAll(rc)
+			rc.Close()
+			assert.NoError(t, err)
+			assert.Equal(t, "v1", string(body))
+			assert.Equal(t, info.ETag, etag)
+
+			_, _, err = c.ReaderIfNoneMatch(tc.path, info.ETag)
+			assert.True(t, errors.Is(err, ErrNotModified), "got %v", err)
+
+			require.NoError(t, c.WriteIfMatch(tc.path, []byte("v3"), info.ETag))
+			err = c.WriteIfMatch(tc.path, []byte("v4"), info.ETag)
+			assert.True(t, errors.Is(err, ErrPreconditionFailed), "got %v", err)
+
+			rc, err = c.Reader(tc.path)
+			require.NoError(t, err)
+			body, err = io.ReadAll(rc)
+			rc.Close()
+			assert.NoError(t, err)
+			assert.Equal(t, "v3", string(body))
+		})
+	}
+}
+
+func TestLocalWriteIfAbsentConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lock")
+	c := &Client{ctx: context.Background()}
+
+	var wg sync.WaitGroup
+	results := make([]error, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.WriteIfAbsent(path, []byte(fmt.Sprintf("writer %d", i)))
+		}(i)
+	}
+	wg.Wait()
+
+	winners := 0
+	for _, err := range results {
+		if err == nil {
+			winners++
+		} else {
+			assert.True(t, errors.Is(err, ErrPreconditionFailed), "got %v", err)
+		}
+	}
+	assert.Equal(t, 1, winners)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must be cleaned up")
+}
+
+func TestConditionalUnsupportedPaths(t *testing.T) {
+	c := &Client{ctx: context.Background()}
+	for _, path := range []string{"https://example.com/file", "sftp://host/file", "zip+file:///tmp/a.zip!/b"} {
+		assert.True(t, errors.Is(c.WriteIfAbsent(path, nil), errors.ErrUnsupported), path)
+		assert.True(t, errors.Is(c.WriteIfMatch(path, nil, `"etag"`), errors.ErrUnsupported), path)
+		_, _, err := c.ReaderIfNoneMatch(path, `"etag"`)
+		assert.True(t, errors.Is(err, errors.ErrUnsupported), path)
+	}
+}
+
+func TestConditionalWritesDryRunAndAudit(t *testing.T) {
+	handler := newFakeS3Handler()
+	c := newFakeS3Client(handler)
+	c.DryRun = &DryRunJournal{}
+	local := filepath.Join(t.TempDir(), "lock")
+
+	require.NoError(t, c.WriteIfAbsent("s3://bucket/lock", []byte("a")))
+	require.NoError(t, c.WriteIfMatch(local, []byte("bb"), `"etag"`))
+	assert.Equal(t, []DryRunEntry{
+		{Op: "write", Path: "s3://bucket/lock", Size: 1},
+		{Op: "write", Path: local, Size: 2},
+	}, c.DryRun.Entries())
+	assert.Empty(t, fakeObjectIDs(handler))
+	_, err := os.Stat(local)
+	assert.True(t, os.IsNotExist(err))
+
+	c.DryRun = nil
+	sink := &memoryAuditSink{}
+	c.Audit = &AuditLog{Sink: sink}
+	require.NoError(t, c.WriteIfAbsent(local, []byte("a")))
+	records := sink.summary(t)
+	require.Len(t, records, 2)
+	assert.Equal(t, AuditSuccess, records[1].Outcome)
+}
+
+func TestReaderIfNoneMatchRateLimitAndProgressLocal(t *testing.T) {
+	recorder := &progressRecorder{}
+	c := &Client{ctx: context.Background(), RateLimit: &RateLimit{ReadBytesPerSecond: 1000000}, Progress: recorder.record}
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, bytes.Repeat([]byte("r"), 1500000), 0644))
+
+	start := time.Now()
+	rc, _, err := c.ReaderIfNoneMatch(path, "")
+	require.NoError(t, err)
+	n, err := io.Copy(io.Discard, rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, int64(1500000), n)
+	// The first second of tokens is available immediately, the rest takes half a second
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	recorder.check(t, 1500000, 1500000)
+}
+
+func TestConditionalWritesRateLimitLocal(t *testing.T) {
+	c := &Client{ctx: context.Background(), RateLimit: &RateLimit{WriteBytesPerSecond: 1000000}}
+	path := filepath.Join(t.TempDir(), "file")
+
+	start := time.Now()
+	require.NoError(t, c.WriteIfAbsent(path, bytes.Repeat([]byte("w"), 1500000)))
+	// The first second of tokens is available immediately, the rest takes half a second
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
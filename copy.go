@@ -0,0 +1,179 @@
+package pathio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// maxS3CopyObjectSize is the largest object CopyObject can copy
+const maxS3CopyObjectSize = 5 << 30
+
+// Copy copies the object at src to dst. Copies between S3 paths of up to 5 GiB are done by S3
+// with CopyObject. Other copies stream src from Reader to dst, so src doesn't need to fit in
+// memory; S3 destinations are uploaded in parts with a manager.Uploader. src can be any
+// readable path and dst a local, S3 or SFTP path.
+func (c *Client) Copy(src, dst string) error {
+	if c.DryRun != nil {
+		return c.recordCopy("copy", src, dst)
+	}
+	progress := c.newProgress(-1)
+	err := c.audited(AuditRecord{Op: "copy", Path: dst, Src: src}, func() error {
+		return c.copy(src, dst, progress)
+	})
+	if err == nil {
+		progress.finish()
+	}
+	return err
+}
+
+// Move moves the file at src to dst, which can be on different kinds of paths, by copying it
+// and deleting src once the copy succeeded. Moving a file onto itself, even through different
+// spellings of its path, returns an error instead of deleting it.
+func (c *Client) Move(src, dst string) error {
+	if samePath(src, dst) {
+		return fmt.Errorf("can't move %s onto itself", src)
+	}
+	if c.DryRun != nil {
+		return c.recordCopy("move", src, dst)
+	}
+	if err := c.Copy(src, dst); err != nil {
+		return err
+	}
+	return c.Delete(src)
+}
+
+// samePath reports whether a and b name the same file or object once parsed, with local paths
+// made absolute
+func samePath(a, b string) bool {
+	pa, err := parsePath(a)
+	if err != nil {
+		return false
+	}
+	pb, err := parsePath(b)
+	if err != nil {
+		return false
+	}
+	if pa.Scheme() == schemeFile && pb.Scheme() == schemeFile {
+		absA, errA := filepath.Abs(pa.Key())
+		absB, errB := filepath.Abs(pb.Key())
+		return errA == nil && errB == nil && absA == absB
+	}
+	return pa.String() == pb.String()
+}
+
+func (c *Client) copy(src, dst string, progress *progressTracker) error {
+	if isS3Path(src) && isS3Path(dst) {
+		srcConn, err := c.s3ObjectConnectionInformation(src, c.Region)
+		if err != nil {
+			return err
+		}
+		dstConn, err := c.s3ObjectConnectionInformation(dst, c.Region)
+		if err != nil {
+			return err
+		}
+		info, err := statS3(c.ctx, srcConn)
+		if err != nil {
+			return err
+		}
+		progress.setTotal(info.Size)
+		if info.Size <= maxS3CopyObjectSize {
+			if err := copyS3Object(c.ctx, srcConn, dstConn, c.disableS3Encryption); err != nil {
+				return err
+			}
+			progress.add(info.Size)
+			return nil
+		}
+	} else if progress != nil {
+		if info, err := c.Stat(src); err == nil {
+			progress.setTotal(info.Size)
+		}
+	}
+
+	rc, err := c.reader(src)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	var input io.Reader = rc
+	if progress != nil {
+		input = progressReadCloser{rc, progress}
+	}
+	return c.writeStream(dst, input)
+}
+
+// writeStream writes everything read from input to the path, without seeking
+func (c *Client) writeStream(path string, input io.Reader) error {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return err
+		}
+		uploader := manager.NewUploader(s3UploadAPI{s3Conn.handler})
+		_, err = uploader.Upload(c.ctx, newS3PutObjectInput(s3Conn, input, c.disableS3Encryption))
+		return err
+	}
+	if isHTTPPath(path) {
+		return errHTTPReadOnly("write", path)
+	}
+	if isSFTPPath(path) {
+		sftpConn, err := c.sftpConnectionInformation(path)
+		if err != nil {
+			return err
+		}
+		defer sftpConn.Close()
+		return writeToSFTP(sftpConn, c.limitWriter(input, path))
+	}
+	if isArchivePath(path) {
+		return errArchiveReadOnly("write", path)
+	}
+	if err := writeToLocalFile(path, c.limitWriter(input, path), c.fileMode(), c.dirMode()); err != nil {
+		return err
+	}
+	return c.writeLocalSidecar(path, nil)
+}
+
+// copyS3Object copies an S3 object with CopyObject
+func copyS3Object(ctx context.Context, src, dst s3Connection, disableEncryption bool) error {
+	params := &s3.CopyObjectInput{
+		Bucket:     aws.String(dst.bucket),
+		Key:        aws.String(dst.key),
+		CopySource: aws.String((&url.URL{Path: src.bucket + "/" + src.key}).EscapedPath()),
+	}
+	if !disableEncryption {
+		params.ServerSideEncryption = aesAlgo
+	}
+	_, err := dst.handler.CopyObject(ctx, params)
+	return err
+}
+
+// s3UploadAPI adapts an s3Handler to the manager.UploadAPIClient used by manager.Uploader
+type s3UploadAPI struct {
+	handler s3Handler
+}
+
+func (a s3UploadAPI) PutObject(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return a.handler.PutObject(ctx, input)
+}
+
+func (a s3UploadAPI) UploadPart(ctx context.Context, input *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	return a.handler.UploadPart(ctx, input)
+}
+
+func (a s3UploadAPI) CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return a.handler.CreateMultipartUpload(ctx, input)
+}
+
+func (a s3UploadAPI) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return a.handler.CompleteMultipartUpload(ctx, input)
+}
+
+func (a s3UploadAPI) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return a.handler.AbortMultipartUpload(ctx, input)
+}
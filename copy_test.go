@@ -0,0 +1,71 @@
+package pathio
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	handler := newFakeS3Handler()
+	handler.put("bucket", "dir/a file.csv", "from s3")
+	c := newFakeS3Client(handler)
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local.csv")
+	require.NoError(t, os.WriteFile(local, []byte("from local"), 0644))
+
+	// S3 to S3 is done server side, including keys that need escaping
+	require.NoError(t, c.Copy("s3://bucket/dir/a file.csv", "s3://other-bucket/copy.csv"))
+	assert.Equal(t, "from s3", readFakeObject(t, c, "s3://other-bucket/copy.csv"))
+
+	require.NoError(t, c.Copy("s3://bucket/dir/a file.csv", filepath.Join(dir, "nested", "downloaded.csv")))
+	downloaded, err := os.ReadFile(filepath.Join(dir, "nested", "downloaded.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "from s3", string(downloaded))
+
+	require.NoError(t, c.Copy(local, "s3://bucket/uploaded.csv"))
+	assert.Equal(t, "from local", readFakeObject(t, c, "s3://bucket/uploaded.csv"))
+
+	// Larger inputs are streamed to S3 in parts
+	large := strings.Repeat("x", 6<<20)
+	require.NoError(t, os.WriteFile(local, []byte(large), 0644))
+	require.NoError(t, c.Copy(local, "s3://bucket/large"))
+	assert.Equal(t, large, readFakeObject(t, c, "s3://bucket/large"))
+	info, err := c.Stat("s3://bucket/large")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(info.ETag, `-2"`), "expected a multipart ETag, got %s", info.ETag)
+}
+
+func TestCopyErrors(t *testing.T) {
+	c := &Client{ctx: context.Background()}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.WriteFile(src, []byte("data"), 0644))
+
+	assert.True(t, errors.Is(c.Copy(src, "https://example.com/file"), errors.ErrUnsupported))
+	assert.True(t, errors.Is(c.Copy(filepath.Join(dir, "missing"), filepath.Join(dir, "dst")), os.ErrNotExist))
+}
+
+func TestMoveOntoItself(t *testing.T) {
+	handler := newFakeS3Handler()
+	handler.put("bucket", "a.csv", "a")
+	c := newFakeS3Client(handler)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.csv")
+	require.NoError(t, os.WriteFile(src, []byte("data"), 0644))
+
+	assert.ErrorContains(t, c.Move(src, src), "onto itself")
+	assert.ErrorContains(t, c.Move(src, dir+"/./a.csv"), "onto itself")
+	assert.ErrorContains(t, c.Move(src, "file://"+src), "onto itself")
+	assert.ErrorContains(t, c.Move("s3://bucket/a.csv", "S3://bucket//a.csv"), "onto itself")
+	data, err := os.ReadFile(src)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.Equal(t, "a", readFakeObject(t, c, "s3://bucket/a.csv"))
+}
@@ -0,0 +1,94 @@
+package pathio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DiffEntry is a file that differs between the two trees compared by Diff.
+type DiffEntry struct {
+	// Path is the slash separated path of the file relative to the roots.
+	Path string
+	// A and B describe the file under each root, and are nil where it doesn't exist.
+	A, B *FileInfo
+}
+
+// DiffResult lists the files that differ between two trees, sorted by path.
+type DiffResult struct {
+	// Added files only exist under b, and Removed files only exist under a.
+	Added   []DiffEntry
+	Removed []DiffEntry
+	// Changed files exist under both roots with a different size or content.
+	Changed []DiffEntry
+}
+
+// Diff compares the trees under the directories or S3 prefixes a and b. Files with the same
+// size are compared by content: S3 objects with the same ETag are equal, and other files are
+// compared by MD5, using the ETag of S3 objects uploaded in one part and reading the rest.
+// Files whose modification time alone differs aren't changed.
+func (c *Client) Diff(a, b string) (*DiffResult, error) {
+	aFiles, err := c.listTree(a)
+	if err != nil {
+		return nil, err
+	}
+	bFiles, err := c.listTree(b)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DiffResult{}
+	var both []string
+	for rel, info := range aFiles {
+		if bInfo, ok := bFiles[rel]; !ok {
+			result.Removed = append(result.Removed, DiffEntry{Path: rel, A: info})
+		} else if info.Size != bInfo.Size {
+			result.Changed = append(result.Changed, DiffEntry{Path: rel, A: info, B: bInfo})
+		} else {
+			both = append(both, rel)
+		}
+	}
+	for rel, info := range bFiles {
+		if _, ok := aFiles[rel]; !ok {
+			result.Added = append(result.Added, DiffEntry{Path: rel, B: info})
+		}
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	eachConcurrently(both, defaultSyncConcurrency, func(rel string) {
+		aPath, bPath := joinTreePath(a, rel), joinTreePath(b, rel)
+		same, err := c.sameContent(aPath, bPath, *aFiles[rel], *bFiles[rel])
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to compare %s to %s: %s", aPath, bPath, err))
+		} else if !same {
+			result.Changed = append(result.Changed, DiffEntry{Path: rel, A: aFiles[rel], B: bFiles[rel]})
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, entries := range [][]DiffEntry{result.Added, result.Removed, result.Changed} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	}
+	return result, nil
+}
+
+// sameContent reports whether two files of the same size have the same content
+func (c *Client) sameContent(aPath, bPath string, aInfo, bInfo FileInfo) (bool, error) {
+	if isS3Path(aPath) && isS3Path(bPath) && aInfo.ETag == bInfo.ETag {
+		return true, nil
+	}
+	aSum, err := c.checksum(aPath, aInfo)
+	if err != nil {
+		return false, err
+	}
+	bSum, err := c.checksum(bPath, bInfo)
+	if err != nil {
+		return false, err
+	}
+	return aSum == bSum, nil
+}
@@ -0,0 +1,67 @@
+package pathio
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diffPaths(entries []DiffEntry) []string {
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
+
+func TestDiff(t *testing.T) {
+	before := map[string]string{"same.csv": "same", "resized.csv": "short", "rewritten.csv": "old!", "gone.csv": "x"}
+	after := map[string]string{"same.csv": "same", "resized.csv": "longer", "rewritten.csv": "new!", "sub/new.csv": "y"}
+	tests := []struct {
+		desc string
+		a, b string
+	}{
+		{desc: "local to local", a: "local", b: "local"},
+		{desc: "local to s3", a: "local", b: "s3://bucket/today"},
+		{desc: "s3 to s3", a: "s3://bucket/yesterday/", b: "s3://bucket/today"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := newFakeS3Client(newFakeS3Handler())
+			a, b := test.a, test.b
+			if a == "local" {
+				a = filepath.Join(t.TempDir(), "a")
+			}
+			if b == "local" {
+				b = filepath.Join(t.TempDir(), "b")
+			}
+			writeTestTree(t, c, a, before)
+			writeTestTree(t, c, b, after)
+
+			result, err := c.Diff(a, b)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"sub/new.csv"}, diffPaths(result.Added))
+			assert.Equal(t, []string{"gone.csv"}, diffPaths(result.Removed))
+			assert.Equal(t, []string{"resized.csv", "rewritten.csv"}, diffPaths(result.Changed))
+
+			require.Len(t, result.Added, 1)
+			assert.Nil(t, result.Added[0].A)
+			assert.Equal(t, int64(1), result.Added[0].B.Size)
+			resized := result.Changed[0]
+			assert.Equal(t, int64(5), resized.A.Size)
+			assert.Equal(t, int64(6), resized.B.Size)
+
+			result, err = c.Diff(a, a)
+			require.NoError(t, err)
+			assert.Equal(t, &DiffResult{}, result)
+		})
+	}
+}
+
+func TestDiffMissingRoot(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	_, err := c.Diff(filepath.Join(t.TempDir(), "missing"), t.TempDir())
+	assert.True(t, isNotExist(err))
+}
@@ -0,0 +1,257 @@
+package pathio
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// md5ETagPattern matches the ETag of objects whose ETag is the MD5 of their content
+var md5ETagPattern = regexp.MustCompile(`^"?[0-9a-f]{32}"?$`)
+
+// multipartETagPattern matches the ETag of multipart uploads, the MD5 of their parts' MD5s
+// followed by the number of parts
+var multipartETagPattern = regexp.MustCompile(`^"?([0-9a-f]{32})-([0-9]+)"?$`)
+
+// Download writes the contents of the specified path to w and returns the number of bytes
+// written. S3 objects are split into ranged GETs of Client.DownloadPartSize bytes, of which
+// Client.DownloadConcurrency run at once. Every part is requested with If-Match on the ETag
+// the object had when the download started, so a download never mixes two versions of an
+// object, and a part whose body fails mid-transfer is retried. If w is also an io.ReaderAt
+// (such as an *os.File), the written data is checked against the full object checksum S3
+// stores for the object (SHA-256, SHA-1, CRC64NVME, CRC32C or CRC32), or else against its ETag
+// when it is the MD5 of the object or of its parts. Objects encrypted with KMS or customer keys
+// and without a full object checksum aren't checked. Other paths are copied sequentially from
+// Reader.
+func (c *Client) Download(path string, w io.WriterAt) (int64, error) {
+	progress := c.newProgress(-1)
+	n, err := c.download(path, w, progress)
+	if err == nil {
+		progress.finish()
+	}
+	return n, err
+}
+
+func (c *Client) download(path string, w io.WriterAt, progress *progressTracker) (int64, error) {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return 0, err
+		}
+		return downloadS3(c.ctx, s3Conn, w, c.downloadPartSize(), c.downloadConcurrency(), progress)
+	}
+	if progress != nil {
+		if info, err := c.Stat(path); err == nil {
+			progress.setTotal(info.Size)
+		}
+		w = progressWriterAt{w, progress}
+	}
+	rc, err := c.reader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	return io.Copy(io.NewOffsetWriter(w, 0), rc)
+}
+
+func (c *Client) downloadPartSize() int64 {
+	if c.DownloadPartSize > 0 {
+		return c.DownloadPartSize
+	}
+	return manager.DefaultDownloadPartSize
+}
+
+func (c *Client) downloadConcurrency() int {
+	if c.DownloadConcurrency > 0 {
+		return c.DownloadConcurrency
+	}
+	return manager.DefaultDownloadConcurrency
+}
+
+// downloadS3 downloads an S3 object to w with a manager.Downloader
+func downloadS3(ctx context.Context, s3Conn s3Connection, w io.WriterAt, partSize int64, concurrency int, progress *progressTracker) (int64, error) {
+	head, err := s3Conn.handler.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(s3Conn.bucket),
+		Key:          aws.String(s3Conn.key),
+		ChecksumMode: s3Types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return 0, err
+	}
+	size, etag := aws.ToInt64(head.ContentLength), aws.ToString(head.ETag)
+	progress.setTotal(size)
+	if size == 0 {
+		// S3 can't satisfy a ranged GET of an empty object
+		return 0, nil
+	}
+
+	downloader := manager.NewDownloader(s3DownloadAPI{s3Conn.handler}, func(d *manager.Downloader) {
+		d.PartSize = partSize
+		d.Concurrency = concurrency
+	})
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Key:    aws.String(s3Conn.key),
+	}
+	if etag != "" {
+		params.IfMatch = aws.String(etag)
+	}
+	var dst io.WriterAt = w
+	if progress != nil {
+		dst = progressWriterAt{w, progress}
+	}
+	n, err := downloader.Download(ctx, dst, params)
+	if isS3PreconditionFailed(err) {
+		return n, fmt.Errorf("%w: %s changed during the download", ErrPreconditionFailed, s3Conn.path())
+	} else if err != nil {
+		return n, err
+	}
+	if n != size {
+		return n, fmt.Errorf("incomplete download of %s: got %d of %d bytes", s3Conn.path(), n, size)
+	}
+	if ra, ok := w.(io.ReaderAt); ok {
+		if err := validateS3Download(ctx, s3Conn, ra, n, head); err != nil {
+			return n, fmt.Errorf("failed to validate download of %s: %s", s3Conn.path(), err)
+		}
+	}
+	return n, nil
+}
+
+// validateS3Download checks the size bytes of a downloaded object read from r against the
+// object's full object checksum, or else its MD5 or multipart ETag
+func validateS3Download(ctx context.Context, s3Conn s3Connection, r io.ReaderAt, size int64, head *s3.HeadObjectOutput) error {
+	if algorithm, want, hash := fullObjectChecksum(head); hash != nil {
+		if _, err := io.Copy(hash, io.NewSectionReader(r, 0, size)); err != nil {
+			return err
+		}
+		if got := base64.StdEncoding.EncodeToString(hash.Sum(nil)); got != want {
+			return fmt.Errorf("%s checksum %s does not match %s", algorithm, got, want)
+		}
+		return nil
+	}
+	etag := aws.ToString(head.ETag)
+	if etagIsMD5(etag, head) {
+		return validateMD5(io.NewSectionReader(r, 0, size), etag)
+	}
+	if multipartETagPattern.MatchString(etag) && !etagIsEncrypted(head) {
+		return validateMultipartETag(ctx, s3Conn, r, etag)
+	}
+	return nil
+}
+
+// crc64NVME is the reversed CRC64NVME polynomial, as crc64.MakeTable expects
+const crc64NVME = 0x9a6c9329ac4bc9b5
+
+// fullObjectChecksum returns the algorithm, base64 value and a new hash of the strongest
+// checksum of the whole object in a HeadObject response made with ChecksumMode enabled, or a
+// nil hash if it has none. Composite checksums of multipart uploads, whose value ends with the
+// number of parts, are checksums of the parts' checksums and are skipped.
+func fullObjectChecksum(head *s3.HeadObjectOutput) (string, string, hash.Hash) {
+	candidates := []struct {
+		algorithm string
+		value     *string
+		newHash   func() hash.Hash
+	}{
+		{"SHA-256", head.ChecksumSHA256, sha256.New},
+		{"SHA-1", head.ChecksumSHA1, sha1.New},
+		{"CRC64NVME", head.ChecksumCRC64NVME, func() hash.Hash { return crc64.New(crc64.MakeTable(crc64NVME)) }},
+		{"CRC32C", head.ChecksumCRC32C, func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) }},
+		{"CRC32", head.ChecksumCRC32, func() hash.Hash { return crc32.NewIEEE() }},
+	}
+	if head.ChecksumType == s3Types.ChecksumTypeComposite {
+		return "", "", nil
+	}
+	for _, candidate := range candidates {
+		value := aws.ToString(candidate.value)
+		if value != "" && !strings.Contains(value, "-") {
+			return candidate.algorithm, value, candidate.newHash()
+		}
+	}
+	return "", "", nil
+}
+
+// etagIsMD5 reports whether the ETag of an object is the MD5 of its content, which is the case
+// for objects uploaded in a single part that aren't encrypted with KMS or customer keys
+func etagIsMD5(etag string, head *s3.HeadObjectOutput) bool {
+	return md5ETagPattern.MatchString(etag) && !etagIsEncrypted(head)
+}
+
+// etagIsEncrypted reports whether the object is encrypted with KMS or customer keys, whose
+// ETags aren't MD5s of the content
+func etagIsEncrypted(head *s3.HeadObjectOutput) bool {
+	return head.SSECustomerAlgorithm != nil ||
+		head.ServerSideEncryption == s3Types.ServerSideEncryptionAwsKms ||
+		head.ServerSideEncryption == s3Types.ServerSideEncryptionAwsKmsDsse
+}
+
+// validateMultipartETag checks data read from r against the ETag of a multipart upload, the
+// MD5 of the concatenated MD5s of its parts followed by the number of parts. The size of each
+// part is read with a HeadObject request for the part.
+func validateMultipartETag(ctx context.Context, s3Conn s3Connection, r io.ReaderAt, etag string) error {
+	match := multipartETagPattern.FindStringSubmatch(etag)
+	parts, err := strconv.Atoi(match[2])
+	if err != nil || parts < 1 {
+		return fmt.Errorf("invalid multipart ETag %s", etag)
+	}
+	sums := md5.New()
+	var offset int64
+	for part := 1; part <= parts; part++ {
+		head, err := s3Conn.handler.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:     aws.String(s3Conn.bucket),
+			Key:        aws.String(s3Conn.key),
+			PartNumber: aws.Int32(int32(part)),
+			IfMatch:    aws.String(etag),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get the size of part %d: %s", part, err)
+		}
+		size := aws.ToInt64(head.ContentLength)
+		partHash := md5.New()
+		if _, err := io.Copy(partHash, io.NewSectionReader(r, offset, size)); err != nil {
+			return err
+		}
+		sums.Write(partHash.Sum(nil))
+		offset += size
+	}
+	if got := fmt.Sprintf("%x-%d", sums.Sum(nil), parts); got != strings.Trim(etag, `"`) {
+		return fmt.Errorf("multipart md5 %s does not match ETag %s", got, strings.Trim(etag, `"`))
+	}
+	return nil
+}
+
+// validateMD5 checks the MD5 of the data read from r against an MD5 ETag
+func validateMD5(r io.Reader, etag string) error {
+	hash := md5.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return err
+	}
+	if got, want := hex.EncodeToString(hash.Sum(nil)), strings.Trim(etag, `"`); got != want {
+		return fmt.Errorf("md5 %s does not match ETag %s", got, want)
+	}
+	return nil
+}
+
+// s3DownloadAPI adapts an s3Handler to the manager.DownloadAPIClient used by manager.Downloader
+type s3DownloadAPI struct {
+	handler s3Handler
+}
+
+func (a s3DownloadAPI) GetObject(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return a.handler.GetObject(ctx, input)
+}
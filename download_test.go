@@ -0,0 +1,157 @@
+package pathio
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyS3Handler fails the body of the first failures ranged GETs halfway through, and can
+// replace the object after the first GET to simulate a concurrent overwrite
+type flakyS3Handler struct {
+	*fakeS3Handler
+	failures  int32
+	overwrite func()
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (f *flakyS3Handler) GetObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	f.ranges = append(f.ranges, aws.ToString(input.Range))
+	overwrite := f.overwrite
+	f.overwrite = nil
+	f.mu.Unlock()
+
+	resp, err := f.fakeS3Handler.GetObject(ctx, input)
+	if overwrite != nil {
+		overwrite()
+	}
+	if err != nil || atomic.AddInt32(&f.failures, -1) < 0 {
+		return resp, err
+	}
+	resp.Body = io.NopCloser(io.MultiReader(
+		io.LimitReader(resp.Body, aws.ToInt64(resp.ContentLength)/2),
+		errReader{errors.New("connection reset by peer")},
+	))
+	return resp, nil
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestDownloadS3(t *testing.T) {
+	body := strings.Repeat("0123456789", 1000)
+	handler := &flakyS3Handler{fakeS3Handler: newFakeS3Handler(), failures: 2}
+	handler.put("bucket", "export.csv", body)
+	client := &Client{ctx: context.Background(), handler: handler, DownloadPartSize: 1024, DownloadConcurrency: 4}
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "export.csv"))
+	require.NoError(t, err)
+	defer file.Close()
+	n, err := client.Download("s3://bucket/export.csv", file)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)), n)
+
+	written, err := os.ReadFile(file.Name())
+	require.NoError(t, err)
+	assert.Equal(t, body, string(written))
+	// 10 parts, 2 of which were retried
+	assert.Len(t, handler.ranges, 12)
+	assert.Contains(t, handler.ranges, "bytes=9216-10239")
+}
+
+func TestDownloadS3ObjectChanged(t *testing.T) {
+	handler := &flakyS3Handler{fakeS3Handler: newFakeS3Handler()}
+	handler.put("bucket", "export.csv", strings.Repeat("a", 4096))
+	handler.overwrite = func() { handler.put("bucket", "export.csv", strings.Repeat("b", 4096)) }
+	client := &Client{ctx: context.Background(), handler: handler, DownloadPartSize: 1024, DownloadConcurrency: 1}
+
+	buf := manager.NewWriteAtBuffer(nil)
+	_, err := client.Download("s3://bucket/export.csv", buf)
+	assert.True(t, errors.Is(err, ErrPreconditionFailed), "got %v", err)
+}
+
+func TestDownloadS3Empty(t *testing.T) {
+	handler := newFakeS3Handler()
+	handler.put("bucket", "empty", "")
+	buf := manager.NewWriteAtBuffer(nil)
+	n, err := newFakeS3Client(handler).Download("s3://bucket/empty", buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestValidateMD5(t *testing.T) {
+	assert.NoError(t, validateMD5(strings.NewReader("hello"), `"5d41402abc4b2a76b9719d911017c592"`))
+	assert.Error(t, validateMD5(strings.NewReader("hellO"), `"5d41402abc4b2a76b9719d911017c592"`))
+	assert.True(t, etagIsMD5(`"5d41402abc4b2a76b9719d911017c592"`, &s3.HeadObjectOutput{}))
+	assert.False(t, etagIsMD5(`"5d41402abc4b2a76b9719d911017c592-3"`, &s3.HeadObjectOutput{}))
+	assert.False(t, etagIsMD5(`"5d41402abc4b2a76b9719d911017c592"`, &s3.HeadObjectOutput{ServerSideEncryption: "aws:kms"}))
+}
+
+func TestValidateS3Download(t *testing.T) {
+	handler := newFakeS3Handler()
+	handler.put("bucket", "single", "hello")
+	handler.put("bucket", "multipart", "hello world")
+	// A multipart upload of "hello " and "world"
+	first, second := md5.Sum([]byte("hello ")), md5.Sum([]byte("world"))
+	obj := handler.objects["bucket/multipart"]
+	obj.etag = fmt.Sprintf(`"%x-2"`, md5.Sum(append(first[:], second[:]...)))
+	obj.partSizes = []int64{6, 5}
+
+	validate := func(key, data string) error {
+		s3Conn := s3Connection{handler, "bucket", key}
+		head, err := handler.HeadObject(context.Background(), &s3.HeadObjectInput{
+			Bucket: aws.String("bucket"), Key: aws.String(key), ChecksumMode: s3Types.ChecksumModeEnabled,
+		})
+		require.NoError(t, err)
+		return validateS3Download(context.Background(), s3Conn, strings.NewReader(data), int64(len(data)), head)
+	}
+	assert.NoError(t, validate("single", "hello"))
+	assert.ErrorContains(t, validate("single", "hellO"), "CRC64NVME checksum")
+	assert.NoError(t, validate("multipart", "hello world"))
+	assert.ErrorContains(t, validate("multipart", "hello World"), "multipart md5")
+
+	// SHA-256 is preferred, and composite checksums are skipped
+	sum := sha256.Sum256([]byte("hello"))
+	sha := base64.StdEncoding.EncodeToString(sum[:])
+	assert.NoError(t, validateS3Download(context.Background(), s3Connection{}, strings.NewReader("hello"), 5,
+		&s3.HeadObjectOutput{ChecksumSHA256: aws.String(sha), ChecksumCRC32: aws.String("AAAAAA==")}))
+	assert.ErrorContains(t, validateS3Download(context.Background(), s3Connection{}, strings.NewReader("hellO"), 5,
+		&s3.HeadObjectOutput{ChecksumSHA256: aws.String(sha)}), "SHA-256 checksum")
+	assert.NoError(t, validateS3Download(context.Background(), s3Connection{}, strings.NewReader("hellO"), 5,
+		&s3.HeadObjectOutput{ChecksumSHA256: aws.String(sha + "-2")}))
+}
+
+func TestDownloadLocal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, []byte("local contents"), 0644))
+	buf := manager.NewWriteAtBuffer(nil)
+	n, err := (&Client{ctx: context.Background()}).Download(path, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(14), n)
+	assert.Equal(t, "local contents", string(buf.Bytes()))
+}
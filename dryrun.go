@@ -0,0 +1,69 @@
+package pathio
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DryRunJournal records the operations of a Client in dry-run mode, set with Client.DryRun.
+type DryRunJournal struct {
+	// Log gets a line for each operation as it is recorded, if set.
+	Log io.Writer
+
+	mu      sync.Mutex
+	entries []DryRunEntry
+}
+
+// DryRunEntry is an operation a Client in dry-run mode would have done.
+type DryRunEntry struct {
+	// Op is "write", "delete", "copy", "move", "tag", "legal-hold", "remove-legal-hold" or
+	// "restore".
+	Op   string
+	Path string
+	// Dst is the destination of a copy or move.
+	Dst string
+	// Size is the number of bytes written, copied or moved, and 0 for other operations.
+	Size int64
+	// Options are the options of a write made with WriteWithOptions or WriteReaderWithOptions.
+	Options *WriteOptions
+}
+
+// String describes the operation, such as "copy s3://bucket/a to /tmp/a (12 bytes)".
+func (e DryRunEntry) String() string {
+	switch {
+	case e.Dst != "":
+		return fmt.Sprintf("%s %s to %s (%d bytes)", e.Op, e.Path, e.Dst, e.Size)
+	case e.Op != "write":
+		return fmt.Sprintf("%s %s", e.Op, e.Path)
+	}
+	return fmt.Sprintf("%s %s (%d bytes)", e.Op, e.Path, e.Size)
+}
+
+// Entries returns the recorded operations, in the order they were recorded.
+func (j *DryRunJournal) Entries() []DryRunEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]DryRunEntry{}, j.entries...)
+}
+
+// record adds an operation to the journal
+func (j *DryRunJournal) record(entry DryRunEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, entry)
+	if j.Log != nil {
+		fmt.Fprintf(j.Log, "(dry run) %s\n", entry)
+	}
+}
+
+// recordCopy records a copy or move from src in the dry-run journal, failing as the copy would
+// if src can't be read
+func (c *Client) recordCopy(op, src, dst string) error {
+	info, err := c.Stat(src)
+	if err != nil {
+		return err
+	}
+	c.DryRun.record(DryRunEntry{Op: op, Path: src, Dst: dst, Size: info.Size})
+	return nil
+}
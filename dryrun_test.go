@@ -0,0 +1,125 @@
+package pathio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	handler := newFakeS3Handler()
+	handler.put("bucket", "src/a.csv", "a,b")
+	handler.put("bucket", "old.csv", "old")
+	c := newFakeS3Client(handler)
+	var log bytes.Buffer
+	c.DryRun = &DryRunJournal{Log: &log}
+	dir := t.TempDir()
+	local := filepath.Join(dir, "out.csv")
+
+	require.NoError(t, c.Write(local, []byte("hello")))
+	opts := WriteOptions{CacheControl: "no-cache"}
+	require.NoError(t, c.WriteWithOptions("s3://bucket/new.csv", []byte("a,b"), opts))
+	require.NoError(t, c.Delete("s3://bucket/old.csv"))
+	require.NoError(t, c.Copy("s3://bucket/src/a.csv", "s3://bucket/dst/a.csv"))
+	require.NoError(t, c.Move("s3://bucket/src/a.csv", local))
+	assert.True(t, isNotExist(c.Copy("s3://bucket/missing.csv", local)))
+
+	opts.ContentType = "text/csv; charset=utf-8"
+	assert.Equal(t, []DryRunEntry{
+		{Op: "write", Path: local, Size: 5},
+		{Op: "write", Path: "s3://bucket/new.csv", Size: 3, Options: &opts},
+		{Op: "delete", Path: "s3://bucket/old.csv"},
+		{Op: "copy", Path: "s3://bucket/src/a.csv", Dst: "s3://bucket/dst/a.csv", Size: 3},
+		{Op: "move", Path: "s3://bucket/src/a.csv", Dst: local, Size: 3},
+	}, c.DryRun.Entries())
+	assert.Equal(t, "(dry run) write "+local+" (5 bytes)\n"+
+		"(dry run) write s3://bucket/new.csv (3 bytes)\n"+
+		"(dry run) delete s3://bucket/old.csv\n"+
+		"(dry run) copy s3://bucket/src/a.csv to s3://bucket/dst/a.csv (3 bytes)\n"+
+		"(dry run) move s3://bucket/src/a.csv to "+local+" (3 bytes)\n", log.String())
+
+	// Nothing changed
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Equal(t, []string{"bucket/old.csv", "bucket/src/a.csv"}, fakeObjectIDs(handler))
+}
+
+func TestDryRunSync(t *testing.T) {
+	handler := newFakeS3Handler()
+	c := newFakeS3Client(handler)
+	writeTestTree(t, c, "s3://bucket/src", map[string]string{"a.csv": "a", "sub/b.csv": "bb"})
+	writeTestTree(t, c, "s3://bucket/dst", map[string]string{"a.csv": "a", "stale.csv": "x"})
+	c.DryRun = &DryRunJournal{}
+
+	result, err := c.Sync("s3://bucket/src", "s3://bucket/dst", SyncOptions{Delete: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sub/b.csv"}, result.Copied)
+	assert.Equal(t, []string{"stale.csv"}, result.Deleted)
+	assert.Equal(t, []DryRunEntry{
+		{Op: "copy", Path: "s3://bucket/src/sub/b.csv", Dst: "s3://bucket/dst/sub/b.csv", Size: 2},
+		{Op: "delete", Path: "s3://bucket/dst/stale.csv"},
+	}, c.DryRun.Entries())
+	assert.Equal(t, []string{"bucket/dst/a.csv", "bucket/dst/stale.csv", "bucket/src/a.csv", "bucket/src/sub/b.csv"}, fakeObjectIDs(handler))
+}
+
+func TestDryRunObjectChanges(t *testing.T) {
+	handler := newFakeS3Handler()
+	handler.put("bucket", "a.csv", "a")
+	c := newFakeS3Client(handler)
+	var log bytes.Buffer
+	c.DryRun = &DryRunJournal{Log: &log}
+
+	require.NoError(t, c.WriteIfAbsent("s3://bucket/b.csv", []byte("b")))
+	require.NoError(t, c.SetTags("s3://bucket/a.csv", map[string]string{"team": "data"}))
+	require.NoError(t, c.SetLegalHold("s3://bucket/a.csv", true))
+	require.NoError(t, c.RestoreArchived("s3://bucket/a.csv", 1, ""))
+	assert.Error(t, c.RestoreArchived("s3://bucket/a.csv", 0, ""))
+	assert.Equal(t, "(dry run) write s3://bucket/b.csv (1 bytes)\n"+
+		"(dry run) tag s3://bucket/a.csv\n"+
+		"(dry run) legal-hold s3://bucket/a.csv\n"+
+		"(dry run) restore s3://bucket/a.csv\n", log.String())
+
+	// Nothing changed
+	assert.Equal(t, []string{"bucket/a.csv"}, fakeObjectIDs(handler))
+	tags, err := c.GetTags("s3://bucket/a.csv")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+	retention, err := c.GetRetention("s3://bucket/a.csv")
+	require.NoError(t, err)
+	assert.False(t, retention.LegalHold)
+}
+
+func TestMove(t *testing.T) {
+	handler := newFakeS3Handler()
+	handler.put("bucket", "src/a.csv", "a,b")
+	c := newFakeS3Client(handler)
+	local := filepath.Join(t.TempDir(), "a.csv")
+
+	require.NoError(t, c.Move("s3://bucket/src/a.csv", local))
+	body, err := os.ReadFile(local)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b", string(body))
+	require.NoError(t, c.Move(local, "s3://bucket/dst/a.csv"))
+	assert.Equal(t, []string{"bucket/dst/a.csv"}, fakeObjectIDs(handler))
+	_, err = os.Stat(local)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Error(t, c.Move("s3://bucket/missing.csv", local))
+}
+
+func fakeObjectIDs(handler *fakeS3Handler) []string {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	var ids []string
+	for id := range handler.objects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,307 @@
+package pathio
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	pathpkg "path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS returns an fs.FS of the files under root, which can be any path pathio can list, such
+// as s3://bucket/prefix or /tmp/work. The returned fs.FS also implements fs.ReadDirFS,
+// fs.StatFS and fs.GlobFS, and its files implement io.Seeker and io.ReaderAt with ranged
+// reads. S3 has no real directories, so a directory exists whenever objects exist under its
+// prefix.
+func (c *Client) FS(root string) fs.FS {
+	return &pathioFS{client: c, root: strings.TrimSuffix(root, "/")}
+}
+
+type pathioFS struct {
+	client *Client
+	root   string
+}
+
+// path returns the pathio path of the named file
+func (f *pathioFS) path(name string) string {
+	if name == "." {
+		return f.root + "/"
+	}
+	return f.root + "/" + name
+}
+
+// Open opens the named file or directory
+func (f *pathioFS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &pathioDir{fsys: f, name: name, info: info}, nil
+	}
+	return &pathioFile{fsys: f, path: f.path(name), info: info}, nil
+}
+
+// Stat returns a fs.FileInfo describing the named file or directory
+func (f *pathioFS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+func (f *pathioFS) stat(op, name string) (fileInfo, error) {
+	if !fs.ValidPath(name) {
+		return fileInfo{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return fileInfo{name: ".", info: FileInfo{IsDir: true}}, nil
+	}
+	info, err := f.client.Stat(f.path(name))
+	if err == nil {
+		return fileInfo{name: pathpkg.Base(name), info: info}, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fileInfo{}, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	// S3 directories only exist as the prefix of other keys
+	if entries, err := f.readDir(name, ""); err == nil && len(entries) > 0 {
+		return fileInfo{name: pathpkg.Base(name), info: FileInfo{IsDir: true}}, nil
+	}
+	return fileInfo{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir reads the named directory and returns its entries sorted by filename
+func (f *pathioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := f.readDir(name, "")
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if len(entries) == 0 && name != "." {
+		// An empty S3 listing can't tell a missing directory from a file
+		info, err := f.stat("readdir", name)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+		}
+	}
+	return entries, nil
+}
+
+// readDir lists the entries of the named directory whose names start with namePrefix
+func (f *pathioFS) readDir(name, namePrefix string) ([]fs.DirEntry, error) {
+	list, err := f.client.listDir(f.path(name), namePrefix)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, 0, len(list))
+	for _, entry := range list {
+		// Keys like "a//b" or "a/./b" have no valid fs.FS name
+		if entry.name == "." || !fs.ValidPath(entry.name) {
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(fileInfo{name: entry.name, info: entry.info}))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Glob returns the names of all files matching pattern. Only the directories named by the
+// pattern are listed, and on S3 the literal prefix of each pattern element narrows the listing.
+func (f *pathioFS) Glob(pattern string) ([]string, error) {
+	if _, err := pathpkg.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasGlobMeta(pattern) {
+		if _, err := f.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := pathpkg.Split(pattern)
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		dir = "."
+	}
+	dirs := []string{dir}
+	if hasGlobMeta(dir) {
+		var err error
+		if dirs, err = f.Glob(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var matches []string
+	for _, d := range dirs {
+		// Like fs.Glob, errors reading directories are ignored
+		entries, err := f.readDir(d, globLiteralPrefix(file))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if matched, _ := pathpkg.Match(file, entry.Name()); matched {
+				matches = append(matches, pathpkg.Join(d, entry.Name()))
+			}
+		}
+	}
+	return matches, nil
+}
+
+// hasGlobMeta reports whether the pattern contains any of the special characters recognized by path.Match
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// globLiteralPrefix returns the part of a path.Match pattern before its first special character
+func globLiteralPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// fileInfo implements fs.FileInfo for a FileInfo
+type fileInfo struct {
+	name string
+	info FileInfo
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.info.Size }
+func (fi fileInfo) ModTime() time.Time { return fi.info.ModTime }
+func (fi fileInfo) IsDir() bool        { return fi.info.IsDir }
+
+// Sys returns the pathio FileInfo
+func (fi fileInfo) Sys() any { return fi.info }
+
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.info.IsDir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// pathioFile is a file opened from a pathioFS. Reads are streamed from the current offset, and
+// seeking or reading at an offset issues a new ranged read.
+type pathioFile struct {
+	fsys   *pathioFS
+	path   string
+	info   fileInfo
+	offset int64
+	reader io.ReadCloser
+}
+
+func (f *pathioFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *pathioFile) Read(p []byte) (int, error) {
+	if f.offset >= f.info.Size() {
+		return 0, io.EOF
+	}
+	if f.reader == nil {
+		rc, err := f.fsys.client.ReadRange(f.path, f.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		f.reader = rc
+	}
+	n, err := f.reader.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *pathioFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.info.Name(), Err: fs.ErrInvalid}
+	}
+	if offset != f.offset && f.reader != nil {
+		f.reader.Close()
+		f.reader = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *pathioFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.info.Name(), Err: fs.ErrInvalid}
+	}
+	if off >= f.info.Size() {
+		return 0, io.EOF
+	}
+	rc, err := f.fsys.client.ReadRange(f.path, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	n, err := io.ReadFull(rc, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *pathioFile) Close() error {
+	if f.reader == nil {
+		return nil
+	}
+	err := f.reader.Close()
+	f.reader = nil
+	return err
+}
+
+// pathioDir is a directory opened from a pathioFS
+type pathioDir struct {
+	fsys    *pathioFS
+	name    string
+	info    fileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *pathioDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *pathioDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir returns the next n entries of the directory, or all remaining entries if n <= 0
+func (d *pathioDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.readDir(d.name, "")
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *pathioDir) Close() error {
+	return nil
+}
@@ -0,0 +1,107 @@
+package pathio
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fsTestFiles = map[string]string{
+	"index.html":              "<h1>{{.}}</h1>",
+	"static/app.js":           "console.log('hi')",
+	"static/css/site.css":     "body {}",
+	"templates/a.tmpl":        `{{define "a"}}A{{end}}`,
+	"templates/b.tmpl":        `{{define "b"}}B{{end}}`,
+	"templates/nested/c.tmpl": `{{define "c"}}C{{end}}`,
+}
+
+func fsTestFileNames() []string {
+	var names []string
+	for name := range fsTestFiles {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestFSLocal(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range fsTestFiles {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0644))
+	}
+	client := &Client{ctx: context.Background()}
+
+	assert.NoError(t, fstest.TestFS(client.FS(dir), fsTestFileNames()...))
+}
+
+func TestFSS3(t *testing.T) {
+	handler := newFakeS3Handler()
+	for name, body := range fsTestFiles {
+		handler.put("bucket", "site/"+name, body)
+	}
+	// Objects outside the root must not be visible
+	handler.put("bucket", "site-other/index.html", "other")
+	client := newFakeS3Client(handler)
+
+	for _, root := range []string{"s3://bucket/site", "s3://bucket/site/"} {
+		fsys := client.FS(root)
+		assert.NoError(t, fstest.TestFS(fsys, fsTestFileNames()...))
+
+		_, err := fs.Stat(fsys, "missing")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+		_, err = fs.ReadDir(fsys, "index.html")
+		assert.Error(t, err)
+	}
+}
+
+func TestFSConsumers(t *testing.T) {
+	handler := newFakeS3Handler()
+	for name, body := range fsTestFiles {
+		handler.put("bucket", "site/"+name, body)
+	}
+	fsys := newFakeS3Client(handler).FS("s3://bucket/site")
+
+	tmpl, err := template.ParseFS(fsys, "templates/*.tmpl")
+	require.NoError(t, err)
+	assert.NotNil(t, tmpl.Lookup("a"))
+	assert.NotNil(t, tmpl.Lookup("b"))
+	assert.Nil(t, tmpl.Lookup("c"))
+
+	matches, err := fs.Glob(fsys, "*/*.js")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"static/app.js"}, matches)
+
+	server := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer server.Close()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/static/css/site.css", nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=5-6")
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "{}", string(body))
+
+	var walked []string
+	err = fs.WalkDir(fsys, "static", func(path string, d fs.DirEntry, err error) error {
+		walked = append(walked, path)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "static,static/app.js,static/css,static/css/site.css", strings.Join(walked, ","))
+}
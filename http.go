@@ -0,0 +1,163 @@
+package pathio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// isHTTPPath reports whether the path is an http:// or https:// URL
+func isHTTPPath(path string) bool {
+	return hasScheme(path, schemeHTTP) || hasScheme(path, schemeHTTPS)
+}
+
+// httpClient returns the http.Client used for HTTP(S) paths
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// errHTTPReadOnly is returned for operations that HTTP(S) paths do not support
+func errHTTPReadOnly(op, path string) error {
+	return fmt.Errorf("%w: cannot %s http(s) path %s, http(s) paths are read-only", errors.ErrUnsupported, op, path)
+}
+
+// httpFileReader converts an HTTP(S) URL into an io.ReadCloser
+func httpFileReader(ctx context.Context, client *http.Client, path string) (io.ReadCloser, error) {
+	resp, err := doHTTPRequest(ctx, client, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, httpStatusError("read", path, resp)
+	}
+	return resp.Body, nil
+}
+
+// httpRangeReader returns an io.ReadCloser for a byte range of an HTTP(S) URL
+func httpRangeReader(ctx context.Context, client *http.Client, path string, offset, length int64) (io.ReadCloser, error) {
+	resp, err := doHTTPRequest(ctx, client, http.MethodGet, path, http.Header{
+		"Range": []string{rangeHeader(offset, length)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// The server ignored the Range header, so skip to the range ourselves
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if length < 0 {
+			return resp.Body, nil
+		}
+		return readCloser{io.LimitReader(resp.Body, length), resp.Body}, nil
+	default:
+		resp.Body.Close()
+		return nil, httpStatusError("read", path, resp)
+	}
+}
+
+// existsHTTP determines if an HTTP(S) URL exists
+func existsHTTP(ctx context.Context, client *http.Client, path string) (bool, error) {
+	_, err := statHTTP(ctx, client, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// statHTTP returns the FileInfo for an HTTP(S) URL using a HEAD request. Presigned S3 URLs
+// are only signed for GET, so if the server rejects the HEAD request we fall back to a GET
+// of the first byte.
+func statHTTP(ctx context.Context, client *http.Client, path string) (FileInfo, error) {
+	resp, err := doHTTPRequest(ctx, client, http.MethodHead, path, nil)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return httpFileInfo(resp, resp.ContentLength), nil
+	case http.StatusForbidden, http.StatusMethodNotAllowed:
+	default:
+		return FileInfo{}, httpStatusError("stat", path, resp)
+	}
+
+	resp, err = doHTTPRequest(ctx, client, http.MethodGet, path, http.Header{
+		"Range": []string{rangeHeader(0, 1)},
+	})
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return httpFileInfo(resp, resp.ContentLength), nil
+	case http.StatusPartialContent:
+		return httpFileInfo(resp, contentRangeSize(resp.Header.Get("Content-Range"))), nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Only empty files can't satisfy a range starting at 0
+		return httpFileInfo(resp, 0), nil
+	default:
+		return FileInfo{}, httpStatusError("stat", path, resp)
+	}
+}
+
+// httpFileInfo builds a FileInfo from the headers of an HTTP response
+func httpFileInfo(resp *http.Response, size int64) FileInfo {
+	info := FileInfo{
+		Size:        size,
+		ETag:        resp.Header.Get("ETag"),
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = lastModified
+	}
+	return info
+}
+
+// contentRangeSize parses the complete length from a Content-Range header such as
+// "bytes 0-0/1234". It returns -1 if the length is unknown.
+func contentRangeSize(contentRange string) int64 {
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+func doHTTPRequest(ctx context.Context, client *http.Client, method, path string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	return client.Do(req)
+}
+
+// httpStatusError converts an unexpected HTTP response into an error. Missing resources
+// wrap fs.ErrNotExist.
+func httpStatusError(op, path string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+	}
+	return fmt.Errorf("failed to %s %s: unexpected status %s", op, path, resp.Status)
+}
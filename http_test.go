@@ -0,0 +1,123 @@
+package pathio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newHTTPTestServer(t *testing.T, body string, allowHead bool) *httptest.Server {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodHead && !allowHead {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		http.ServeContent(w, r, "file.txt", modTime, strings.NewReader(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPReader(t *testing.T) {
+	server := newHTTPTestServer(t, "hello world", true)
+	client := &Client{ctx: context.Background(), HTTPClient: server.Client()}
+
+	reader, err := client.Reader(server.URL + "/file.txt")
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, "hello world", string(body))
+
+	_, err = client.Reader(server.URL + "/missing.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestHTTPReadRange(t *testing.T) {
+	server := newHTTPTestServer(t, "hello world", true)
+	client := &Client{ctx: context.Background(), HTTPClient: server.Client()}
+
+	testCases := []struct {
+		desc     string
+		offset   int64
+		length   int64
+		expected string
+	}{
+		{desc: "Middle", offset: 6, length: 3, expected: "wor"},
+		{desc: "ToEnd", offset: 6, length: -1, expected: "world"},
+		{desc: "Empty", offset: 3, length: 0, expected: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			reader, err := client.ReadRange(server.URL+"/file.txt", tc.offset, tc.length)
+			assert.NoError(t, err)
+			body, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.NoError(t, reader.Close())
+			assert.Equal(t, tc.expected, string(body))
+		})
+	}
+}
+
+func TestHTTPReadRangeIgnoredByServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+	client := &Client{ctx: context.Background(), HTTPClient: server.Client()}
+
+	reader, err := client.ReadRange(server.URL+"/file.txt", 6, 3)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, "wor", string(body))
+}
+
+func TestHTTPStatAndExists(t *testing.T) {
+	for _, allowHead := range []bool{true, false} {
+		server := newHTTPTestServer(t, "hello world", allowHead)
+		client := &Client{ctx: context.Background(), HTTPClient: server.Client()}
+
+		info, err := client.Stat(server.URL + "/file.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(11), info.Size)
+		assert.Equal(t, `"abc"`, info.ETag)
+		assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), info.ModTime.UTC())
+
+		exists, err := client.Exists(server.URL + "/file.txt")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+
+		exists, err = client.Exists(server.URL + "/missing.txt")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	}
+}
+
+func TestHTTPReadOnly(t *testing.T) {
+	client := &Client{ctx: context.Background()}
+
+	err := client.Write("https://example.com/file.txt", []byte("data"))
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+
+	err = client.Delete("https://example.com/file.txt")
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+
+	_, err = client.ListFiles("https://example.com/")
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}
@@ -0,0 +1,292 @@
+package pathio
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var (
+	// ErrLockHeld is returned by Lock when another owner holds an unexpired lease on the path.
+	ErrLockHeld = errors.New("lock is held")
+	// ErrLockLost is returned once a Lease could not be renewed before it expired, or was taken
+	// over or removed by someone else.
+	ErrLockLost = errors.New("lock lost")
+)
+
+// maxLockAttempts bounds how often Lock retries when the lock object changes under it
+const maxLockAttempts = 3
+
+// lockRecord is the JSON body of a lock object
+type lockRecord struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// Lease is a lock held on a pathio path, acquired with Lock. It is renewed in the background
+// until Unlock is called or the lease is lost.
+type Lease struct {
+	client *Client
+	path   string
+	owner  string
+	ttl    time.Duration
+
+	mu sync.Mutex
+	// written is the last record we wrote, and etag its ETag once it has been read back
+	written lockRecord
+	etag    string
+	err     error
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+	lostOnce sync.Once
+	lost     chan struct{}
+}
+
+// Lock acquires a lease on path by creating a lock object holding a unique owner ID and an
+// expiry ttl from now, using WriteIfAbsent so only one caller can create it. A lock object
+// whose expiry has passed is stale and is taken over with WriteIfMatch. If another owner holds
+// the lease, Lock returns an error wrapping ErrLockHeld without waiting.
+//
+// The lease is renewed every ttl/3 until Unlock is called. If it can't be renewed before it
+// expires, Lost is closed and Err returns an error wrapping ErrLockLost, and the caller should
+// stop the work the lock protects. Expiry is compared against the local clock, so the clocks of
+// the hosts sharing a lock must agree to well within ttl. The path can be either a local file
+// path or an S3 path.
+func Lock(client *Client, path string, ttl time.Duration) (*Lease, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid lock ttl %s: must be positive", ttl)
+	}
+	owner, err := newLockOwner()
+	if err != nil {
+		return nil, err
+	}
+	l := &Lease{
+		client: client,
+		path:   path,
+		owner:  owner,
+		ttl:    ttl,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}
+	if err := l.acquire(); err != nil {
+		return nil, err
+	}
+	go l.renewLoop()
+	return l, nil
+}
+
+// newLockOwner returns an owner ID that is unique across hosts and processes
+func newLockOwner() (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(nonce)), nil
+}
+
+// Owner returns the owner ID written to the lock object.
+func (l *Lease) Owner() string {
+	return l.owner
+}
+
+// Lost returns a channel that is closed when the lease is lost.
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns an error wrapping ErrLockLost if the lease was lost, and nil otherwise.
+func (l *Lease) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if errors.Is(l.err, ErrLockLost) {
+		return l.err
+	}
+	return nil
+}
+
+// Unlock stops renewing the lease and deletes the lock object if we still hold it. It returns
+// an error wrapping ErrLockLost if the lease had already been lost.
+func (l *Lease) Unlock() error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	if l.etag == "" {
+		if err := l.confirm(); err != nil {
+			return err
+		}
+	}
+	err := l.client.deleteIfMatch(l.path, l.etag)
+	if errors.Is(err, ErrPreconditionFailed) {
+		return l.markLost(fmt.Errorf("%w: %s was taken over or removed", ErrLockLost, l.path))
+	} else if err != nil {
+		return err
+	}
+	l.err = fmt.Errorf("lock %s was already unlocked", l.path)
+	return nil
+}
+
+// acquire creates the lock object, or takes it over if it is stale
+func (l *Lease) acquire() error {
+	for attempt := 0; attempt < maxLockAttempts; attempt++ {
+		err := l.write("")
+		if err == nil {
+			return l.confirm()
+		} else if !errors.Is(err, ErrPreconditionFailed) {
+			return err
+		}
+
+		current, etag, err := l.client.readLockRecord(l.path)
+		if isNotExist(err) {
+			// The lock was released since we tried to create it
+			continue
+		} else if err != nil {
+			return err
+		}
+		if time.Now().Before(current.Expires) {
+			return fmt.Errorf("%w: %s is held by %s until %s", ErrLockHeld, l.path, current.Owner, current.Expires.Format(time.RFC3339))
+		}
+
+		// The lease is stale, so take it over unless someone else does first
+		err = l.write(etag)
+		if err == nil {
+			return l.confirm()
+		} else if !errors.Is(err, ErrPreconditionFailed) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s kept changing while acquiring it", ErrLockHeld, l.path)
+}
+
+// write writes a new record expiring ttl from now, with WriteIfMatch if etag is set and
+// WriteIfAbsent otherwise
+func (l *Lease) write(etag string) error {
+	record := lockRecord{Owner: l.owner, Expires: time.Now().Add(l.ttl).UTC()}
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if etag == "" {
+		err = l.client.WriteIfAbsent(l.path, body)
+	} else {
+		err = l.client.WriteIfMatch(l.path, body, etag)
+	}
+	if err != nil {
+		return err
+	}
+	l.written = record
+	l.etag = ""
+	return nil
+}
+
+// confirm reads back the record we last wrote to learn its ETag
+func (l *Lease) confirm() error {
+	current, etag, err := l.client.readLockRecord(l.path)
+	if isNotExist(err) {
+		return l.markLost(fmt.Errorf("%w: %s was removed", ErrLockLost, l.path))
+	} else if err != nil {
+		return err
+	}
+	if current.Owner != l.owner || !current.Expires.Equal(l.written.Expires) {
+		return l.markLost(fmt.Errorf("%w: %s was taken over by %s", ErrLockLost, l.path, current.Owner))
+	}
+	l.etag = etag
+	return nil
+}
+
+// renewLoop renews the lease every ttl/3 until it is stopped or lost
+func (l *Lease) renewLoop() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-l.lost:
+			return
+		case <-ticker.C:
+			l.renew()
+		}
+	}
+}
+
+// renew extends the lease. Errors other than losing the lease are retried on the next tick
+// for as long as the lease hasn't expired.
+func (l *Lease) renew() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	err := l.renewLocked()
+	if err != nil && !errors.Is(err, ErrLockLost) && !time.Now().Before(l.written.Expires) {
+		l.markLost(fmt.Errorf("%w: could not renew %s before it expired: %s", ErrLockLost, l.path, err))
+	}
+}
+
+func (l *Lease) renewLocked() error {
+	if l.etag == "" {
+		if err := l.confirm(); err != nil {
+			return err
+		}
+	}
+	err := l.write(l.etag)
+	if errors.Is(err, ErrPreconditionFailed) {
+		return l.markLost(fmt.Errorf("%w: %s was taken over or removed", ErrLockLost, l.path))
+	} else if err != nil {
+		return err
+	}
+	return l.confirm()
+}
+
+// markLost records that the lease is lost and returns err
+func (l *Lease) markLost(err error) error {
+	l.err = err
+	l.lostOnce.Do(func() { close(l.lost) })
+	return err
+}
+
+// readLockRecord reads the lock object at path along with its ETag
+func (c *Client) readLockRecord(path string) (lockRecord, string, error) {
+	rc, etag, err := c.ReaderIfNoneMatch(path, "")
+	if err != nil {
+		return lockRecord{}, "", err
+	}
+	defer rc.Close()
+	body, err := io.ReadAll(rc)
+	if err != nil {
+		return lockRecord{}, "", err
+	}
+	var record lockRecord
+	if err := json.Unmarshal(body, &record); err != nil {
+		return lockRecord{}, "", fmt.Errorf("invalid lock object %s: %s", path, err)
+	}
+	return record, etag, nil
+}
+
+// isNotExist reports whether err means the object doesn't exist, for local and S3 paths
+func isNotExist(err error) bool {
+	var noSuchKey *s3Types.NoSuchKey
+	return errors.Is(err, fs.ErrNotExist) || errors.As(err, &noSuchKey)
+}
@@ -0,0 +1,114 @@
+package pathio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lockTestCases(t *testing.T) []struct {
+	desc   string
+	client *Client
+	path   string
+} {
+	return []struct {
+		desc   string
+		client *Client
+		path   string
+	}{
+		{
+			desc:   "Local",
+			client: &Client{ctx: context.Background()},
+			path:   filepath.Join(t.TempDir(), "locks", "nightly"),
+		},
+		{
+			desc:   "S3",
+			client: newFakeS3Client(newFakeS3Handler()),
+			path:   "s3://bucket/locks/nightly",
+		},
+	}
+}
+
+func TestLock(t *testing.T) {
+	for _, tc := range lockTestCases(t) {
+		t.Run(tc.desc, func(t *testing.T) {
+			lease, err := Lock(tc.client, tc.path, 150*time.Millisecond)
+			require.NoError(t, err)
+
+			record, _, err := tc.client.readLockRecord(tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, lease.Owner(), record.Owner)
+
+			// The lease outlives its ttl because it is renewed in the background
+			time.Sleep(400 * time.Millisecond)
+			_, err = Lock(tc.client, tc.path, time.Second)
+			assert.True(t, errors.Is(err, ErrLockHeld), "got %v", err)
+			assert.NoError(t, lease.Err())
+
+			require.NoError(t, lease.Unlock())
+			exists, err := tc.client.Exists(tc.path)
+			assert.NoError(t, err)
+			assert.False(t, exists)
+			assert.Error(t, lease.Unlock())
+
+			other, err := Lock(tc.client, tc.path, time.Second)
+			require.NoError(t, err)
+			assert.NotEqual(t, lease.Owner(), other.Owner())
+			assert.NoError(t, other.Unlock())
+		})
+	}
+}
+
+func TestLockTakesOverStaleLease(t *testing.T) {
+	for _, tc := range lockTestCases(t) {
+		t.Run(tc.desc, func(t *testing.T) {
+			stale, err := json.Marshal(lockRecord{Owner: "crashed", Expires: time.Now().Add(-time.Minute)})
+			require.NoError(t, err)
+			require.NoError(t, tc.client.Write(tc.path, stale))
+
+			lease, err := Lock(tc.client, tc.path, time.Second)
+			require.NoError(t, err)
+			record, _, err := tc.client.readLockRecord(tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, lease.Owner(), record.Owner)
+			assert.NoError(t, lease.Unlock())
+		})
+	}
+}
+
+func TestLockLost(t *testing.T) {
+	for _, tc := range lockTestCases(t) {
+		t.Run(tc.desc, func(t *testing.T) {
+			lease, err := Lock(tc.client, tc.path, 150*time.Millisecond)
+			require.NoError(t, err)
+
+			// Someone ignores the lock and overwrites it
+			intruder, err := json.Marshal(lockRecord{Owner: "intruder", Expires: time.Now().Add(time.Minute)})
+			require.NoError(t, err)
+			require.NoError(t, tc.client.Write(tc.path, intruder))
+
+			select {
+			case <-lease.Lost():
+			case <-time.After(time.Second):
+				t.Fatal("lease was not lost")
+			}
+			assert.True(t, errors.Is(lease.Err(), ErrLockLost), "got %v", lease.Err())
+			assert.True(t, errors.Is(lease.Unlock(), ErrLockLost))
+
+			record, _, err := tc.client.readLockRecord(tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, "intruder", record.Owner, "a lost lease must not delete the new owner's lock")
+		})
+	}
+}
+
+func TestLockInvalidTTL(t *testing.T) {
+	_, err := Lock(&Client{ctx: context.Background()}, filepath.Join(t.TempDir(), "lock"), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,194 @@
+package pathio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// localSidecarSuffix ends the name of the hidden file holding the metadata of a local file
+const localSidecarSuffix = ".pathio.json"
+
+// WriteOptions sets the headers and metadata of an object written with WriteWithOptions or
+// WriteReaderWithOptions. S3 stores them with the object, local paths store them in a hidden
+// sidecar file next to the file, and other paths ignore them.
+type WriteOptions struct {
+	// ContentType is detected from the extension of the path, or from the content with
+	// http.DetectContentType, if empty.
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+	// Metadata is stored as x-amz-meta-* headers on S3.
+	Metadata map[string]string
+	// Tags are the object tags, as set by SetTags.
+	Tags map[string]string
+	// StorageClass is the S3 storage class, such as "STANDARD_IA" or "GLACIER_IR". It defaults
+	// to the bucket's, and isn't stored for local paths.
+	StorageClass string
+	// ObjectLockMode and RetainUntil protect an S3 object from being deleted or overwritten
+	// until the date, in a bucket with Object Lock enabled. They must be set together, and
+	// writes to other paths with them fail.
+	ObjectLockMode ObjectLockMode
+	RetainUntil    time.Time
+}
+
+// localSidecar is the content of the sidecar file of a local file
+type localSidecar struct {
+	ContentType        string            `json:"content_type,omitempty"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	CacheControl       string            `json:"cache_control,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+}
+
+// WriteWithOptions writes a byte array to the specified path with the headers and metadata of
+// opts.
+func (c *Client) WriteWithOptions(path string, input []byte, opts WriteOptions) error {
+	return c.WriteReaderWithOptions(path, bytes.NewReader(input), opts)
+}
+
+// WriteReaderWithOptions writes all the data read from the io.ReadSeeker to the specified path
+// with the headers and metadata of opts.
+func (c *Client) WriteReaderWithOptions(path string, input io.ReadSeeker, opts WriteOptions) error {
+	if err := opts.validateObjectLock(path); err != nil {
+		return err
+	}
+	if opts.ContentType == "" {
+		contentType, err := detectContentType(path, input)
+		if err != nil {
+			return err
+		}
+		opts.ContentType = contentType
+	}
+	return c.writeReaderWithOptions(path, input, &opts)
+}
+
+// detectContentType returns the content type of the path's extension, or else sniffs it from
+// the start of the input
+func detectContentType(path string, input io.ReadSeeker) (string, error) {
+	if contentType := mime.TypeByExtension(pathpkg.Ext(path)); contentType != "" {
+		return contentType, nil
+	}
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(input, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// applyToPutObject sets the options on PutObject parameters
+func (opts *WriteOptions) applyToPutObject(params *s3.PutObjectInput) {
+	if opts == nil {
+		return
+	}
+	params.ContentType = optionalString(opts.ContentType)
+	params.ContentDisposition = optionalString(opts.ContentDisposition)
+	params.CacheControl = optionalString(opts.CacheControl)
+	params.Metadata = opts.Metadata
+	params.Tagging = optionalString(encodeTagging(opts.Tags))
+	params.StorageClass = s3Types.StorageClass(opts.StorageClass)
+	params.ObjectLockMode = s3Types.ObjectLockMode(opts.ObjectLockMode)
+	if !opts.RetainUntil.IsZero() {
+		params.ObjectLockRetainUntilDate = aws.Time(opts.RetainUntil)
+	}
+}
+
+// applyToCreateMultipartUpload sets the options on CreateMultipartUpload parameters
+func (opts *WriteOptions) applyToCreateMultipartUpload(params *s3.CreateMultipartUploadInput) {
+	if opts == nil {
+		return
+	}
+	params.ContentType = optionalString(opts.ContentType)
+	params.ContentDisposition = optionalString(opts.ContentDisposition)
+	params.CacheControl = optionalString(opts.CacheControl)
+	params.Metadata = opts.Metadata
+	params.Tagging = optionalString(encodeTagging(opts.Tags))
+	params.StorageClass = s3Types.StorageClass(opts.StorageClass)
+	params.ObjectLockMode = s3Types.ObjectLockMode(opts.ObjectLockMode)
+	if !opts.RetainUntil.IsZero() {
+		params.ObjectLockRetainUntilDate = aws.Time(opts.RetainUntil)
+	}
+}
+
+// optionalString returns nil for an empty string, so the SDK doesn't send an empty header
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// localSidecarPath returns the path of the sidecar file of a local file
+func localSidecarPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+localSidecarSuffix)
+}
+
+// isLocalSidecar reports whether a file name is that of a sidecar file, which listings skip
+func isLocalSidecar(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, localSidecarSuffix)
+}
+
+// writeLocalSidecar replaces the sidecar of a local file with the options, or removes it if
+// opts is nil, as S3 replaces the metadata of an object it overwrites
+func (c *Client) writeLocalSidecar(path string, opts *WriteOptions) error {
+	if opts == nil {
+		return removeLocalSidecar(path)
+	}
+	return c.saveLocalSidecar(path, localSidecar{
+		ContentType:        opts.ContentType,
+		ContentDisposition: opts.ContentDisposition,
+		CacheControl:       opts.CacheControl,
+		Metadata:           opts.Metadata,
+		Tags:               opts.Tags,
+	})
+}
+
+// saveLocalSidecar writes the sidecar of a local file
+func (c *Client) saveLocalSidecar(path string, sidecar localSidecar) error {
+	body, err := json.Marshal(sidecar)
+	if err != nil {
+		return err
+	}
+	return writeToLocalFile(localSidecarPath(path), bytes.NewReader(body), c.fileMode(), c.dirMode())
+}
+
+// readLocalSidecar returns the sidecar of a local file, which is empty if there is none
+func readLocalSidecar(path string) (localSidecar, error) {
+	var sidecar localSidecar
+	body, err := os.ReadFile(localSidecarPath(path))
+	if os.IsNotExist(err) {
+		return sidecar, nil
+	} else if err != nil {
+		return sidecar, err
+	}
+	if err := json.Unmarshal(body, &sidecar); err != nil {
+		return sidecar, fmt.Errorf("invalid metadata file for %s: %s", path, err)
+	}
+	return sidecar, nil
+}
+
+func removeLocalSidecar(path string) error {
+	if err := os.Remove(localSidecarPath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package pathio
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteWithOptionsS3(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	require.NoError(t, c.WriteWithOptions("s3://bucket/reports/2024", []byte("a,b\n1,2\n"), WriteOptions{
+		ContentType:        "text/csv",
+		ContentDisposition: `attachment; filename="2024.csv"`,
+		CacheControl:       "max-age=3600",
+		Metadata:           map[string]string{"owner": "reports"},
+	}))
+
+	info, err := c.Stat("s3://bucket/reports/2024")
+	require.NoError(t, err)
+	assert.Equal(t, "text/csv", info.ContentType)
+	assert.Equal(t, `attachment; filename="2024.csv"`, info.ContentDisposition)
+	assert.Equal(t, "max-age=3600", info.CacheControl)
+	assert.Equal(t, map[string]string{"owner": "reports"}, info.Metadata)
+}
+
+func TestWriteWithOptionsDetectsContentType(t *testing.T) {
+	tests := []struct {
+		desc string
+		path string
+		body string
+		want string
+	}{
+		{desc: "extension", path: "s3://bucket/roster.csv", body: "a,b", want: "text/csv; charset=utf-8"},
+		{desc: "sniffed html", path: "s3://bucket/index", body: "<html><body>hi</body></html>", want: "text/html; charset=utf-8"},
+		{desc: "sniffed binary", path: "s3://bucket/blob", body: "\x00\x01\x02", want: "application/octet-stream"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := newFakeS3Client(newFakeS3Handler())
+			require.NoError(t, c.WriteReaderWithOptions(test.path, strings.NewReader(test.body), WriteOptions{}))
+			info, err := c.Stat(test.path)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, info.ContentType)
+			// Sniffing rewinds the input
+			assert.Equal(t, test.body, readFakeObject(t, c, test.path))
+		})
+	}
+}
+
+func TestWriteWithOptionsResumable(t *testing.T) {
+	c := newResumableTestClient(t, newFakeS3Handler())
+	input := strings.Repeat("x", 2500)
+	require.NoError(t, c.WriteReaderWithOptions("s3://bucket/snapshot", strings.NewReader(input), WriteOptions{
+		CacheControl: "no-cache",
+		Metadata:     map[string]string{"source": "backup"},
+	}))
+
+	info, err := c.Stat("s3://bucket/snapshot")
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", info.ContentType)
+	assert.Equal(t, "no-cache", info.CacheControl)
+	assert.Equal(t, map[string]string{"source": "backup"}, info.Metadata)
+	assert.Equal(t, input, readFakeObject(t, c, "s3://bucket/snapshot"))
+}
+
+func TestWriteWithOptionsLocal(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+	require.NoError(t, c.WriteWithOptions(path, []byte(`{}`), WriteOptions{
+		CacheControl: "no-store",
+		Metadata:     map[string]string{"owner": "reports"},
+	}))
+
+	info, err := c.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), info.Size)
+	assert.Equal(t, "application/json", info.ContentType)
+	assert.Equal(t, "no-store", info.CacheControl)
+	assert.Equal(t, map[string]string{"owner": "reports"}, info.Metadata)
+
+	// The sidecar is hidden from listings
+	_, err = os.Stat(filepath.Join(dir, ".report.json.pathio.json"))
+	require.NoError(t, err)
+	files, err := c.ListFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"report.json"}, files)
+	tree, err := c.listTree(dir)
+	require.NoError(t, err)
+	assert.Len(t, tree, 1)
+	assert.Contains(t, tree, "report.json")
+
+	// A plain write replaces the metadata, as it does on S3
+	require.NoError(t, c.Write(path, []byte(`[]`)))
+	info, err = c.Stat(path)
+	require.NoError(t, err)
+	assert.Empty(t, info.ContentType)
+	assert.Nil(t, info.Metadata)
+
+	require.NoError(t, c.WriteWithOptions(path, []byte(`{}`), WriteOptions{}))
+	require.NoError(t, c.Delete(path))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
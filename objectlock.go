@@ -0,0 +1,141 @@
+package pathio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrObjectLocked is returned by Delete when S3 refuses to delete an object because it is under
+// an Object Lock retention period or legal hold. In a versioned bucket, deleting a locked object
+// succeeds by adding a delete marker, and its locked version stays.
+var ErrObjectLocked = errors.New("object is locked")
+
+// ObjectLockMode is the Object Lock retention mode of an S3 object.
+type ObjectLockMode string
+
+const (
+	// ObjectLockGovernance retains objects from users without the
+	// s3:BypassGovernanceRetention permission.
+	ObjectLockGovernance ObjectLockMode = "GOVERNANCE"
+	// ObjectLockCompliance retains objects from every user, including the root account, and
+	// can't be shortened.
+	ObjectLockCompliance ObjectLockMode = "COMPLIANCE"
+)
+
+// Retention is the Object Lock state of an S3 object returned by GetRetention.
+type Retention struct {
+	// Mode and RetainUntil are empty unless the object has a retention period.
+	Mode        ObjectLockMode
+	RetainUntil time.Time
+	LegalHold   bool
+}
+
+// locked reports whether the retention prevents deleting the object at now
+func (r Retention) locked(now time.Time) bool {
+	return r.LegalHold || (r.Mode != "" && now.Before(r.RetainUntil))
+}
+
+// SetLegalHold places or removes a legal hold on the S3 object at path, which prevents it from
+// being deleted until removed regardless of its retention period. The bucket must have Object
+// Lock enabled. Paths other than S3 paths return an error wrapping errors.ErrUnsupported.
+func (c *Client) SetLegalHold(path string, on bool) error {
+	if !isS3Path(path) {
+		return errObjectLockUnsupported(path)
+	}
+	op, status := "remove-legal-hold", s3Types.ObjectLockLegalHoldStatusOff
+	if on {
+		op, status = "legal-hold", s3Types.ObjectLockLegalHoldStatusOn
+	}
+	if c.DryRun != nil {
+		c.DryRun.record(DryRunEntry{Op: op, Path: path})
+		return nil
+	}
+	return c.audited(AuditRecord{Op: op, Path: path}, func() error {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return err
+		}
+		_, err = s3Conn.handler.PutObjectLegalHold(c.ctx, &s3.PutObjectLegalHoldInput{
+			Bucket:    aws.String(s3Conn.bucket),
+			Key:       aws.String(s3Conn.key),
+			LegalHold: &s3Types.ObjectLockLegalHold{Status: status},
+		})
+		return err
+	})
+}
+
+// GetRetention returns the Object Lock retention and legal hold of the S3 object at path. Paths
+// other than S3 paths return an error wrapping errors.ErrUnsupported.
+func (c *Client) GetRetention(path string) (*Retention, error) {
+	if !isS3Path(path) {
+		return nil, errObjectLockUnsupported(path)
+	}
+	s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+	if err != nil {
+		return nil, err
+	}
+	return getS3Retention(c.ctx, s3Conn)
+}
+
+func getS3Retention(ctx context.Context, s3Conn s3Connection) (*Retention, error) {
+	resp, err := s3Conn.handler.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Key:    aws.String(s3Conn.key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Retention{
+		Mode:        ObjectLockMode(resp.ObjectLockMode),
+		RetainUntil: aws.ToTime(resp.ObjectLockRetainUntilDate),
+		LegalHold:   resp.ObjectLockLegalHoldStatus == s3Types.ObjectLockLegalHoldStatusOn,
+	}, nil
+}
+
+// s3DeleteError returns an error wrapping ErrObjectLocked if S3 denied a delete because the
+// object is locked, or else the error as is. Deleting a locked object without a version ID in
+// the versioned buckets Object Lock requires succeeds by adding a delete marker, so only deletes
+// S3 refuses, such as of a locked version, are mapped.
+func s3DeleteError(ctx context.Context, s3Conn s3Connection, err error) error {
+	code := s3ErrorCode(err)
+	if code != "AccessDenied" && code != "InvalidRequest" && s3StatusCode(err) != http.StatusForbidden {
+		return err
+	}
+	retention, headErr := getS3Retention(ctx, s3Conn)
+	if headErr != nil || !retention.locked(time.Now()) {
+		return err
+	}
+	if retention.LegalHold {
+		return fmt.Errorf("%w: %s has a legal hold", ErrObjectLocked, s3Conn.path())
+	}
+	return fmt.Errorf("%w: %s is retained in %s mode until %s", ErrObjectLocked, s3Conn.path(),
+		retention.Mode, retention.RetainUntil.Format(time.RFC3339))
+}
+
+// validateObjectLock checks that the Object Lock options are complete and apply to the path
+func (opts *WriteOptions) validateObjectLock(path string) error {
+	if opts.ObjectLockMode == "" && opts.RetainUntil.IsZero() {
+		return nil
+	}
+	if !isS3Path(path) {
+		return errObjectLockUnsupported(path)
+	}
+	if opts.ObjectLockMode == "" || opts.RetainUntil.IsZero() {
+		return fmt.Errorf("invalid object lock for %s: ObjectLockMode and RetainUntil must be set together", path)
+	}
+	if opts.ObjectLockMode != ObjectLockGovernance && opts.ObjectLockMode != ObjectLockCompliance {
+		return fmt.Errorf("invalid object lock mode %s: must be GOVERNANCE or COMPLIANCE", opts.ObjectLockMode)
+	}
+	return nil
+}
+
+func errObjectLockUnsupported(path string) error {
+	return fmt.Errorf("%w: object lock is only supported on S3 paths, got: %s", errors.ErrUnsupported, path)
+}
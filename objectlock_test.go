@@ -0,0 +1,127 @@
+package pathio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedVersionS3Handler refuses to delete locked objects, as S3 does for deletes of a locked
+// version
+type lockedVersionS3Handler struct {
+	*fakeS3Handler
+}
+
+func (h *lockedVersionS3Handler) DeleteObject(ctx context.Context, input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	head, err := h.HeadObject(ctx, &s3.HeadObjectInput{Bucket: input.Bucket, Key: input.Key})
+	if err == nil {
+		retention := Retention{
+			Mode:        ObjectLockMode(head.ObjectLockMode),
+			RetainUntil: aws.ToTime(head.ObjectLockRetainUntilDate),
+			LegalHold:   head.ObjectLockLegalHoldStatus == s3Types.ObjectLockLegalHoldStatusOn,
+		}
+		if retention.locked(h.now()) {
+			return nil, &smithy.GenericAPIError{Code: "AccessDenied"}
+		}
+	}
+	return h.fakeS3Handler.DeleteObject(ctx, input)
+}
+
+func TestObjectLockRetention(t *testing.T) {
+	c := &Client{ctx: context.Background(), handler: &lockedVersionS3Handler{newFakeS3Handler()}}
+	path := "s3://bucket/audit/2024-03-01.log"
+	retainUntil := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	require.NoError(t, c.WriteWithOptions(path, []byte("entry"), WriteOptions{
+		ObjectLockMode: ObjectLockCompliance,
+		RetainUntil:    retainUntil,
+	}))
+
+	retention, err := c.GetRetention(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Retention{Mode: ObjectLockCompliance, RetainUntil: retainUntil}, retention)
+
+	err = c.Delete(path)
+	assert.True(t, errors.Is(err, ErrObjectLocked))
+	assert.ErrorContains(t, err, "retained in COMPLIANCE mode until "+retainUntil.Format(time.RFC3339))
+	exists, err := c.Exists(path)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestObjectLockLegalHold(t *testing.T) {
+	c := &Client{ctx: context.Background(), handler: &lockedVersionS3Handler{newFakeS3Handler()}}
+	path := "s3://bucket/audit/held.log"
+	require.NoError(t, c.Write(path, []byte("entry")))
+
+	require.NoError(t, c.SetLegalHold(path, true))
+	retention, err := c.GetRetention(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Retention{LegalHold: true}, retention)
+	err = c.Delete(path)
+	assert.True(t, errors.Is(err, ErrObjectLocked))
+	assert.ErrorContains(t, err, "has a legal hold")
+
+	require.NoError(t, c.SetLegalHold(path, false))
+	require.NoError(t, c.Delete(path))
+}
+
+func TestObjectLockDeleteMarker(t *testing.T) {
+	// Deleting a locked object without a version ID adds a delete marker, which S3 allows, and
+	// plain deletes don't look up the retention
+	handler := &countingS3Handler{fakeS3Handler: newFakeS3Handler()}
+	c := &Client{ctx: context.Background(), handler: handler}
+	path := "s3://bucket/audit/held.log"
+	require.NoError(t, c.Write(path, []byte("entry")))
+	require.NoError(t, c.SetLegalHold(path, true))
+	require.NoError(t, c.Delete(path))
+	_, heads := handler.requests()
+	assert.Zero(t, heads)
+	exists, err := c.Exists(path)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestObjectLockExpiredRetention(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	path := "s3://bucket/audit/old.log"
+	require.NoError(t, c.WriteWithOptions(path, []byte("entry"), WriteOptions{
+		ObjectLockMode: ObjectLockGovernance,
+		RetainUntil:    time.Now().Add(-time.Hour),
+	}))
+	require.NoError(t, c.Delete(path))
+}
+
+func TestObjectLockErrors(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	retainUntil := time.Now().Add(time.Hour)
+	tests := []struct {
+		desc string
+		path string
+		opts WriteOptions
+	}{
+		{desc: "mode without date", path: "s3://bucket/key", opts: WriteOptions{ObjectLockMode: ObjectLockGovernance}},
+		{desc: "date without mode", path: "s3://bucket/key", opts: WriteOptions{RetainUntil: retainUntil}},
+		{desc: "invalid mode", path: "s3://bucket/key", opts: WriteOptions{ObjectLockMode: "FOREVER", RetainUntil: retainUntil}},
+		{desc: "local path", path: "/tmp/audit.log", opts: WriteOptions{ObjectLockMode: ObjectLockGovernance, RetainUntil: retainUntil}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			assert.Error(t, c.WriteWithOptions(test.path, []byte("entry"), test.opts))
+		})
+	}
+
+	assert.True(t, errors.Is(c.SetLegalHold("/tmp/audit.log", true), errors.ErrUnsupported))
+	_, err := c.GetRetention("sftp://host/audit.log")
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	exists, err := c.Exists("s3://bucket/key")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
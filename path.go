@@ -0,0 +1,278 @@
+package pathio
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	pathpkg "path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	schemeFile  = "file"
+	schemeS3    = "s3"
+	schemeHTTP  = "http"
+	schemeHTTPS = "https"
+	schemeSFTP  = "sftp"
+
+	maxS3KeyLength = 1024
+)
+
+// s3BucketPattern matches the characters allowed in S3 bucket names; the remaining rules are
+// checked in validateS3Bucket.
+var s3BucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// Path is a parsed pathio path. It can be a local file path, an S3 path (s3://bucket/key), an
+// HTTP(S) URL or an SFTP path (sftp://user@host:port/path). Client methods take paths as
+// strings, so that the Pathio interface doesn't change; pass a Path to them with String. They
+// don't apply the bucket naming rules Parse checks, so legacy bucket names keep working there.
+//
+//	p, err := pathio.Parse("s3://bucket/exports/2024")
+//	csv := p.Join("district-42", "students.csv") // s3://bucket/exports/2024/district-42/students.csv
+type Path struct {
+	scheme string
+	// host is the bucket for S3 paths and the host[:port] for other URLs
+	host string
+	user *url.Userinfo
+	// key is the object key for S3 paths, the URL path for other URLs and the file path for
+	// local paths
+	key   string
+	query string
+}
+
+// Parse parses and normalizes a path. Schemes are case insensitive, S3 keys have their leading
+// slashes removed, and S3 paths without a key (s3://bucket) address the whole bucket. S3 keys
+// can contain "?", so everything after the bucket is the key, and bucket names must follow the
+// S3 naming rules for general purpose buckets. The query string of other URLs is kept, but is
+// not part of the key. Local paths, including file:// URLs, are cleaned with filepath.Clean.
+func Parse(path string) (Path, error) {
+	p, err := parsePath(path)
+	if err != nil {
+		return Path{}, err
+	}
+	if p.scheme == schemeS3 {
+		if err := validateS3Bucket(p.host); err != nil {
+			return Path{}, fmt.Errorf("invalid s3 path %s: %s", path, err)
+		}
+	}
+	return p, nil
+}
+
+// parsePath is Parse without the S3 bucket naming rules, for the paths given to Client methods,
+// which can be in legacy buckets with upper case letters, underscores or long names
+func parsePath(path string) (Path, error) {
+	if path == "" {
+		return Path{}, errors.New("invalid path: path is empty")
+	}
+	scheme, rest, found := strings.Cut(path, "://")
+	if !found || strings.ContainsAny(scheme, `/\`) {
+		return Path{scheme: schemeFile, key: filepath.Clean(path)}, nil
+	}
+
+	switch strings.ToLower(scheme) {
+	case schemeFile:
+		if rest == "" {
+			return Path{}, fmt.Errorf("invalid file path %s", path)
+		}
+		return Path{scheme: schemeFile, key: filepath.Clean(rest)}, nil
+	case schemeS3:
+		return parseS3(path, rest)
+	case schemeHTTP, schemeHTTPS, schemeSFTP:
+		u, err := url.Parse(path)
+		if err != nil {
+			return Path{}, fmt.Errorf("invalid path %s: %s", path, err)
+		}
+		if u.Host == "" {
+			return Path{}, fmt.Errorf("invalid path %s: missing host", path)
+		}
+		key := u.Path
+		if key == "" {
+			key = "/"
+		}
+		return Path{scheme: strings.ToLower(u.Scheme), host: u.Host, user: u.User, key: key, query: u.RawQuery}, nil
+	default:
+		return Path{}, fmt.Errorf("invalid path %s: unsupported scheme %s", path, scheme)
+	}
+}
+
+// parseS3 parses the part of an S3 path after s3://
+func parseS3(path, rest string) (Path, error) {
+	bucket, key, _ := strings.Cut(rest, "/")
+	key = strings.TrimLeft(key, "/")
+	if bucket == "" {
+		return Path{}, fmt.Errorf("invalid s3 path %s: missing bucket", path)
+	}
+	if err := validateS3Key(key); err != nil {
+		return Path{}, fmt.Errorf("invalid s3 path %s: %s", path, err)
+	}
+	return Path{scheme: schemeS3, host: bucket, key: key}, nil
+}
+
+// validateS3Bucket checks the S3 bucket naming rules for general purpose buckets
+func validateS3Bucket(bucket string) error {
+	switch {
+	case bucket == "":
+		return errors.New("missing bucket")
+	case !s3BucketPattern.MatchString(bucket),
+		strings.Contains(bucket, ".."),
+		net.ParseIP(bucket) != nil,
+		strings.HasPrefix(bucket, "xn--"),
+		strings.HasSuffix(bucket, "-s3alias"),
+		strings.HasSuffix(bucket, "--ol-s3"):
+		return fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	return nil
+}
+
+// validateS3Key checks that the key is valid UTF-8 and at most 1024 bytes long
+func validateS3Key(key string) error {
+	if len(key) > maxS3KeyLength {
+		return fmt.Errorf("key is longer than %d bytes", maxS3KeyLength)
+	}
+	if !utf8.ValidString(key) {
+		return errors.New("key is not valid UTF-8")
+	}
+	return nil
+}
+
+// Scheme returns the lower case scheme of the path: "s3", "http", "https", "sftp", or "file"
+// for local paths.
+func (p Path) Scheme() string {
+	return p.scheme
+}
+
+// Bucket returns the bucket of an S3 path, or "" for other paths.
+func (p Path) Bucket() string {
+	if p.scheme != schemeS3 {
+		return ""
+	}
+	return p.host
+}
+
+// Key returns the object key of an S3 path, the URL path of other URLs, or the file path of
+// local paths.
+func (p Path) Key() string {
+	return p.key
+}
+
+// String returns the normalized path.
+func (p Path) String() string {
+	switch p.scheme {
+	case schemeFile:
+		return p.key
+	case schemeS3:
+		return "s3://" + p.host + "/" + p.key
+	default:
+		u := url.URL{Scheme: p.scheme, User: p.user, Host: p.host, Path: p.key, RawQuery: p.query}
+		return u.String()
+	}
+}
+
+// Join returns the path with the elements appended to its key, separated by slashes (or the
+// OS separator for local paths). S3 and URL paths can't be joined above their root, and a
+// trailing slash on the last element is kept so the result can be used as an S3 prefix. The
+// query string is dropped.
+func (p Path) Join(elem ...string) Path {
+	joined := p
+	joined.query = ""
+	if p.scheme == schemeFile {
+		joined.key = filepath.Join(append([]string{p.key}, elem...)...)
+		return joined
+	}
+	key := pathpkg.Join(append([]string{"/", p.key}, elem...)...)
+	if len(elem) > 0 && strings.HasSuffix(elem[len(elem)-1], "/") && key != "/" {
+		key += "/"
+	}
+	joined.key = p.rootKey(key)
+	return joined
+}
+
+// Dir returns the directory containing the path. For S3 and URL paths the directory keeps its
+// trailing slash, so it can be used as a prefix for ListFiles. The query string is dropped.
+func (p Path) Dir() Path {
+	dir := p
+	dir.query = ""
+	if p.scheme == schemeFile {
+		dir.key = filepath.Dir(p.key)
+		return dir
+	}
+	trimmed := strings.TrimSuffix("/"+strings.TrimPrefix(p.key, "/"), "/")
+	dir.key = p.rootKey(trimmed[:strings.LastIndex(trimmed, "/")+1])
+	return dir
+}
+
+// Base returns the last element of the path, ignoring trailing slashes. It returns "" for the
+// root of a bucket or URL.
+func (p Path) Base() string {
+	if p.scheme == schemeFile {
+		return filepath.Base(p.key)
+	}
+	trimmed := strings.Trim(p.key, "/")
+	if trimmed == "" {
+		return ""
+	}
+	return pathpkg.Base(trimmed)
+}
+
+// Ext returns the file name extension of the path, as path.Ext does.
+func (p Path) Ext() string {
+	return pathpkg.Ext(p.Base())
+}
+
+// Rel returns a relative path that is lexically equivalent to target when joined to p. Both
+// paths must have the same scheme and bucket or host.
+func (p Path) Rel(target Path) (string, error) {
+	if p.scheme != target.scheme || p.host != target.host {
+		return "", fmt.Errorf("can't make %s relative to %s", target, p)
+	}
+	if p.scheme == schemeFile {
+		return filepath.Rel(p.key, target.key)
+	}
+	base := strings.Split(strings.Trim(pathpkg.Clean("/"+p.key), "/"), "/")
+	targ := strings.Split(strings.Trim(pathpkg.Clean("/"+target.key), "/"), "/")
+	if base[0] == "" {
+		base = nil
+	}
+	if targ[0] == "" {
+		targ = nil
+	}
+	common := 0
+	for common < len(base) && common < len(targ) && base[common] == targ[common] {
+		common++
+	}
+	rel := make([]string, 0, len(base)-common+len(targ)-common)
+	for range base[common:] {
+		rel = append(rel, "..")
+	}
+	rel = append(rel, targ[common:]...)
+	if len(rel) == 0 {
+		return ".", nil
+	}
+	return strings.Join(rel, "/"), nil
+}
+
+// rootKey converts a slash rooted key to the key format of the path's scheme
+func (p Path) rootKey(key string) string {
+	if p.scheme == schemeS3 {
+		return strings.TrimPrefix(key, "/")
+	}
+	if key == "" {
+		return "/"
+	}
+	return key
+}
+
+// hasScheme reports whether the path starts with scheme://, ignoring case
+func hasScheme(path, scheme string) bool {
+	prefix := scheme + "://"
+	return len(path) >= len(prefix) && strings.EqualFold(path[:len(prefix)], prefix)
+}
+
+// isS3Path reports whether the path is an s3:// path
+func isS3Path(path string) bool {
+	return hasScheme(path, schemeS3)
+}
@@ -0,0 +1,180 @@
+package pathio
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		path           string
+		expectedScheme string
+		expectedBucket string
+		expectedKey    string
+		expectedString string
+	}{
+		{
+			desc:           "S3Path",
+			path:           "s3://bucket/dir/file.csv",
+			expectedScheme: "s3",
+			expectedBucket: "bucket",
+			expectedKey:    "dir/file.csv",
+			expectedString: "s3://bucket/dir/file.csv",
+		},
+		{
+			desc:           "S3BucketWithoutSlash",
+			path:           "s3://bucket",
+			expectedScheme: "s3",
+			expectedBucket: "bucket",
+			expectedString: "s3://bucket/",
+		},
+		{
+			desc:           "S3UpperCaseSchemeAndDoubleSlash",
+			path:           "S3://bucket//dir/",
+			expectedScheme: "s3",
+			expectedBucket: "bucket",
+			expectedKey:    "dir/",
+			expectedString: "s3://bucket/dir/",
+		},
+		{
+			desc:           "S3KeyWithQuestionMark",
+			path:           "s3://bucket/report?v=1.csv",
+			expectedScheme: "s3",
+			expectedBucket: "bucket",
+			expectedKey:    "report?v=1.csv",
+			expectedString: "s3://bucket/report?v=1.csv",
+		},
+		{
+			desc:           "LocalPath",
+			path:           "/tmp/work/../file.csv",
+			expectedScheme: "file",
+			expectedKey:    "/tmp/file.csv",
+			expectedString: "/tmp/file.csv",
+		},
+		{
+			desc:           "FileURL",
+			path:           "file:///tmp/file.csv",
+			expectedScheme: "file",
+			expectedKey:    "/tmp/file.csv",
+			expectedString: "/tmp/file.csv",
+		},
+		{
+			desc:           "HTTPSURL",
+			path:           "HTTPS://example.com/a/b.csv?sig=1",
+			expectedScheme: "https",
+			expectedKey:    "/a/b.csv",
+			expectedString: "https://example.com/a/b.csv?sig=1",
+		},
+		{
+			desc:           "SFTPPath",
+			path:           "sftp://district@example.com:2222/drops/roster.csv",
+			expectedScheme: "sftp",
+			expectedKey:    "/drops/roster.csv",
+			expectedString: "sftp://district@example.com:2222/drops/roster.csv",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			p, err := Parse(tc.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedScheme, p.Scheme())
+			assert.Equal(t, tc.expectedBucket, p.Bucket())
+			assert.Equal(t, tc.expectedKey, p.Key())
+			assert.Equal(t, tc.expectedString, p.String())
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		path          string
+		expectedError string
+	}{
+		{desc: "Empty", path: "", expectedError: "invalid path: path is empty"},
+		{desc: "MissingBucket", path: "s3:///key", expectedError: "invalid s3 path s3:///key: missing bucket"},
+		{desc: "ShortBucket", path: "s3://ab/key", expectedError: `invalid s3 path s3://ab/key: invalid bucket name "ab"`},
+		{desc: "UpperCaseBucket", path: "s3://Bucket/key", expectedError: `invalid s3 path s3://Bucket/key: invalid bucket name "Bucket"`},
+		{desc: "IPBucket", path: "s3://192.168.5.4/key", expectedError: `invalid s3 path s3://192.168.5.4/key: invalid bucket name "192.168.5.4"`},
+		{desc: "DoubleDotBucket", path: "s3://my..bucket/key", expectedError: `invalid s3 path s3://my..bucket/key: invalid bucket name "my..bucket"`},
+		{desc: "LongKey", path: "s3://bucket/" + strings.Repeat("k", 1025), expectedError: "key is longer than 1024 bytes"},
+		{desc: "InvalidUTF8Key", path: "s3://bucket/\xff", expectedError: "key is not valid UTF-8"},
+		{desc: "UnsupportedScheme", path: "gs://bucket/key", expectedError: "invalid path gs://bucket/key: unsupported scheme gs"},
+		{desc: "MissingHost", path: "https:///file", expectedError: "invalid path https:///file: missing host"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := Parse(tc.path)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedError)
+		})
+	}
+}
+
+func TestPathManipulation(t *testing.T) {
+	mustParse := func(path string) Path {
+		p, err := Parse(path)
+		assert.NoError(t, err)
+		return p
+	}
+
+	root := mustParse("s3://bucket/exports")
+	assert.Equal(t, "s3://bucket/exports/2024/students.csv", root.Join("2024", "students.csv").String())
+	assert.Equal(t, "s3://bucket/exports/2024/", root.Join("2024/").String())
+	assert.Equal(t, "s3://bucket/", root.Join("..", "..", "..").String())
+	assert.Equal(t, "s3://bucket/other", mustParse("s3://bucket/exports?versionId=1").Join("../other").String())
+
+	file := mustParse("s3://bucket/exports/2024/students.csv")
+	assert.Equal(t, "s3://bucket/exports/2024/", file.Dir().String())
+	assert.Equal(t, "s3://bucket/exports/", file.Dir().Dir().String())
+	assert.Equal(t, "s3://bucket/", mustParse("s3://bucket/file").Dir().String())
+	assert.Equal(t, "students.csv", file.Base())
+	assert.Equal(t, ".csv", file.Ext())
+	assert.Equal(t, "2024", mustParse("s3://bucket/exports/2024/").Base())
+	assert.Equal(t, "", mustParse("s3://bucket").Base())
+
+	rel, err := root.Rel(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024/students.csv", rel)
+	rel, err = file.Rel(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "../..", rel)
+	_, err = root.Rel(mustParse("s3://other-bucket/exports/file"))
+	assert.EqualError(t, err, "can't make s3://other-bucket/exports/file relative to s3://bucket/exports")
+
+	local := mustParse("/tmp/work")
+	assert.Equal(t, "/tmp/work/a/b.csv", local.Join("a", "b.csv").String())
+	assert.Equal(t, "/tmp", local.Dir().String())
+	rel, err = local.Rel(mustParse("/tmp/work/a/b.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a/b.csv", rel)
+
+	url := mustParse("https://example.com/a/b.csv")
+	assert.Equal(t, "https://example.com/a/", url.Dir().String())
+	assert.Equal(t, "https://example.com/a/c/d.csv", url.Dir().Join("c", "d.csv").String())
+}
+
+func TestLegacyBucketName(t *testing.T) {
+	// Parse enforces the naming rules, but Client methods accept legacy bucket names
+	_, err := Parse("s3://Legacy_Bucket/file.csv")
+	assert.ErrorContains(t, err, `invalid bucket name "Legacy_Bucket"`)
+	handler := newFakeS3Handler()
+	c := newFakeS3Client(handler)
+	require.NoError(t, c.Write("s3://Legacy_Bucket/file.csv", []byte("a,b")))
+	assert.Equal(t, []string{"Legacy_Bucket/file.csv"}, fakeObjectIDs(handler))
+	assert.Equal(t, "a,b", readFakeObject(t, c, "s3://Legacy_Bucket/file.csv"))
+}
+
+func TestS3KeyWithQuestionMark(t *testing.T) {
+	handler := newFakeS3Handler()
+	c := newFakeS3Client(handler)
+	require.NoError(t, c.Write("s3://bucket/report?v=1.csv", []byte("a,b")))
+	assert.Equal(t, []string{"bucket/report?v=1.csv"}, fakeObjectIDs(handler))
+	assert.Equal(t, "a,b", readFakeObject(t, c, "s3://bucket/report?v=1.csv"))
+}
@@ -1,7 +1,12 @@
 // Package pathio is a package that allows writing to and reading from different types of paths transparently.
-// It supports two types of paths:
+// It supports four types of paths:
 //  1. Local file paths
 //  2. S3 File Paths (s3://bucket/key)
+//  3. HTTP(S) URLs (https://host/path), which are read-only
+//  4. SFTP paths (sftp://user@host:port/path), which require Client.SFTP
+//
+// Members of zip and tar archives at any of these paths can be read with archive paths such as
+// zip+s3://bucket/bundle.zip!/inner/file.csv or tar+file:///tmp/x.tar.gz!/a.csv
 //
 // Note that using s3 paths requires setting two environment variables
 //  1. AWS_SECRET_ACCESS_KEY
@@ -14,13 +19,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	awsV2Config "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -31,6 +40,9 @@ import (
 const (
 	defaultLocation = "us-east-1"
 	aesAlgo         = "AES256"
+
+	defaultFileMode os.FileMode = 0644
+	defaultDirMode  os.FileMode = 0700
 )
 
 // generate a mock for Pathio
@@ -47,19 +59,85 @@ type Pathio interface {
 	GeneratePresignedURL(path string, expiration time.Duration) (string, error)
 }
 
-// Client is the pathio client used to access the local file system and S3.
+// FileInfo describes the object at a path, as returned by Stat.
+type FileInfo struct {
+	Size        int64
+	ModTime     time.Time
+	ETag        string
+	ContentType string
+	IsDir       bool
+	// ContentDisposition, CacheControl and Metadata are set by Stat for S3 objects and for
+	// local files written with WriteOptions.
+	ContentDisposition string
+	CacheControl       string
+	Metadata           map[string]string
+	// StorageClass is the storage class of an S3 object, such as "STANDARD_IA" or "GLACIER".
+	StorageClass string
+	// VersionID is the version of an S3 object in a versioned bucket.
+	VersionID string
+}
+
+// Client is the pathio client used to access the local file system, S3, HTTP(S) URLs and SFTP servers.
 // To configure options on the client, create a new Client and call its methods
 // directly.
 //
 //	&Client{
 //		disableS3Encryption: true, // disables encryption
 //		Region: "us-east-1", // hardcodes the s3 region, instead of looking it up
+//		HTTPClient: &http.Client{Timeout: time.Minute}, // used for http(s) paths
 //	}.Write(...)
 type Client struct {
 	ctx                 context.Context
 	disableS3Encryption bool
 	Region              string
 	providedConfig      *aws.Config
+	// handler replaces the live S3 handler for every bucket when set
+	handler s3Handler
+	// HTTPClient is used for http:// and https:// paths. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// SFTP configures authentication for sftp:// paths.
+	SFTP *SFTPConfig
+	// FileMode is the permission of local files written by the client. Defaults to 0644.
+	FileMode os.FileMode
+	// DirMode is the permission of local directories created by the client. Defaults to 0700.
+	DirMode os.FileMode
+	// DownloadPartSize is the size of the ranged GETs Download uses for S3 objects. Defaults to
+	// manager.DefaultDownloadPartSize (5 MiB).
+	DownloadPartSize int64
+	// DownloadConcurrency is the number of parts Download fetches at once. Defaults to
+	// manager.DefaultDownloadConcurrency (5).
+	DownloadConcurrency int
+	// ResumableUploadDir enables resumable uploads to S3 when set. WriteReader uploads inputs
+	// larger than UploadPartSize in parts and records the upload ID and completed parts in a
+	// state file in this directory, so writing the same input to the same path after an
+	// interruption only uploads the missing parts.
+	ResumableUploadDir string
+	// UploadPartSize is the part size of resumable uploads. Defaults to
+	// manager.DefaultUploadPartSize (5 MiB), the minimum S3 allows.
+	UploadPartSize int64
+	// Progress is called during Reader, WriteReader, Download and Copy with the progress of the
+	// transfer. It is called at most once per ProgressInterval, plus once when the transfer
+	// completes.
+	Progress ProgressFunc
+	// ProgressInterval is the minimum time between two calls of Progress. Defaults to 100ms.
+	ProgressInterval time.Duration
+	// RateLimit limits the bandwidth and S3 request rate of the client. Its token buckets are
+	// shared by all of the client's transfers, so it must be set before the client is used.
+	RateLimit *RateLimit
+	// PrefixRateLimits adds limits for paths starting with a prefix, such as "s3://bucket/" or
+	// "s3://bucket/hot/prefix/", on top of RateLimit. Only the longest matching prefix applies.
+	PrefixRateLimits map[string]*RateLimit
+	// DryRun puts the client in dry-run mode when set: Write, WriteReader, the WriteOptions
+	// writes, WriteIfAbsent, WriteIfMatch, Delete, Copy, Move, Sync, SetTags, SetLegalHold and
+	// RestoreArchived record the operations they would do in the journal and return without
+	// touching S3 or the disk. Reads still happen.
+	DryRun *DryRunJournal
+	// Audit records the writes, deletes and copies of the client in an audit log when set,
+	// including those of Move, Sync and the conditional writes.
+	Audit *AuditLog
+
+	rateLimitersOnce sync.Once
+	limiters         *clientRateLimiters
 }
 
 // DefaultClient is the default pathio client called by the Reader, Writer, and
@@ -120,8 +198,16 @@ type S3API interface {
 	s3.HeadObjectAPIClient    // embedded for s3's HeadObject()
 	manager.DownloadAPIClient // embedded for s3's GetObject()
 
-	manager.UploadAPIClient // embedded for s3's PutObject()
+	manager.UploadAPIClient // embedded for s3's PutObject() and multipart uploads
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+	GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	RestoreObject(ctx context.Context, params *s3.RestoreObjectInput, optFns ...func(*s3.Options)) (*s3.RestoreObjectOutput, error)
+	PutObjectLegalHold(ctx context.Context, params *s3.PutObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.PutObjectLegalHoldOutput, error)
+
+	s3.ListPartsAPIClient            // embedded for s3's ListParts()
+	s3.ListMultipartUploadsAPIClient // embedded for s3's ListMultipartUploads()
 }
 
 // s3Handler defines the wrapper interface that pathio uses for AWS access
@@ -135,6 +221,20 @@ type s3Handler interface {
 	ListAllObjects(ctx context.Context, input *s3.ListObjectsV2Input) ([]*s3.ListObjectsV2Output, error)
 	HeadObject(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
 	GeneratePresignedURL(ctx context.Context, bucket, key string, expiration time.Duration) (string, error)
+	PresignGetObject(ctx context.Context, input *s3.GetObjectInput, expiration time.Duration) (*v4.PresignedHTTPRequest, error)
+	PresignPutObject(ctx context.Context, input *s3.PutObjectInput, expiration time.Duration) (*v4.PresignedHTTPRequest, error)
+	PresignPostObject(ctx context.Context, input *s3.PutObjectInput, expiration time.Duration, conditions []interface{}) (*s3.PresignedPostRequest, error)
+	CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error)
+	ListParts(ctx context.Context, input *s3.ListPartsInput) (*s3.ListPartsOutput, error)
+	ListMultipartUploads(ctx context.Context, input *s3.ListMultipartUploadsInput) (*s3.ListMultipartUploadsOutput, error)
+	CopyObject(ctx context.Context, input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error)
+	PutObjectTagging(ctx context.Context, input *s3.PutObjectTaggingInput) (*s3.PutObjectTaggingOutput, error)
+	GetObjectTagging(ctx context.Context, input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error)
+	RestoreObject(ctx context.Context, input *s3.RestoreObjectInput) (*s3.RestoreObjectOutput, error)
+	PutObjectLegalHold(ctx context.Context, input *s3.PutObjectLegalHoldInput) (*s3.PutObjectLegalHoldOutput, error)
 }
 
 type s3Connection struct {
@@ -143,95 +243,328 @@ type s3Connection struct {
 	key     string
 }
 
-// Reader returns an io.Reader for the specified path. The path can either be a local file path
-// or an S3 path. It is the caller's responsibility to close rc.
+// path returns the s3://bucket/key path of the connection
+func (s3Conn s3Connection) path() string {
+	return "s3://" + s3Conn.bucket + "/" + s3Conn.key
+}
+
+// Reader returns an io.Reader for the specified path. The path can either be a local file path,
+// an S3 path, an HTTP(S) URL or an SFTP path. It is the caller's responsibility to close rc.
 func (c *Client) Reader(path string) (rc io.ReadCloser, err error) {
-	if strings.HasPrefix(path, "s3://") {
-		s3Conn, err := c.s3ConnectionInformation(path, c.Region)
+	rc, err = c.reader(path)
+	if err != nil || c.Progress == nil {
+		return rc, err
+	}
+	total := int64(-1)
+	if info, err := c.Stat(path); err == nil && !info.IsDir {
+		total = info.Size
+	}
+	return progressReadCloser{rc, c.newProgress(total)}, nil
+}
+
+// reader implements Reader without progress reporting
+func (c *Client) reader(path string) (io.ReadCloser, error) {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
 		if err != nil {
 			return nil, err
 		}
 		return s3FileReader(c.ctx, s3Conn)
 	}
-	// Local file path
-	return os.Open(path)
+	if isArchivePath(path) {
+		return c.archiveReader(path)
+	}
+	var rc io.ReadCloser
+	var err error
+	if isHTTPPath(path) {
+		rc, err = httpFileReader(c.ctx, c.httpClient(), path)
+	} else if isSFTPPath(path) {
+		sftpConn, connErr := c.sftpConnectionInformation(path)
+		if connErr != nil {
+			return nil, connErr
+		}
+		rc, err = sftpFileReader(sftpConn)
+	} else {
+		// Local file path
+		rc, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.limitReader(rc, path), nil
+}
+
+// ReadRange returns an io.ReadCloser for length bytes of the specified path starting at offset.
+// A negative length reads until the end of the file. The path can either be a local file path,
+// an S3 path, an HTTP(S) URL or an SFTP path. It is the caller's responsibility to close rc.
+func (c *Client) ReadRange(path string, offset, length int64) (rc io.ReadCloser, err error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid range offset %d", offset)
+	}
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return nil, err
+		}
+		return s3FileRangeReader(c.ctx, s3Conn, offset, length)
+	}
+	if isArchivePath(path) {
+		return c.archiveRangeReader(path, offset, length)
+	}
+	if isHTTPPath(path) {
+		rc, err = httpRangeReader(c.ctx, c.httpClient(), path, offset, length)
+	} else if isSFTPPath(path) {
+		sftpConn, connErr := c.sftpConnectionInformation(path)
+		if connErr != nil {
+			return nil, connErr
+		}
+		rc, err = sftpRangeReader(sftpConn, offset, length)
+	} else {
+		rc, err = localRangeReader(path, offset, length)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.limitReader(rc, path), nil
 }
 
-// Write writes a byte array to the specified path. The path can be either a local file path or an
-// S3 path.
+// Write writes a byte array to the specified path. The path can be either a local file path, an
+// S3 path or an SFTP path.
 func (c *Client) Write(path string, input []byte) error {
 	return c.WriteReader(path, bytes.NewReader(input))
 }
 
 // WriteReader writes all the data read from the specified io.Reader to the
-// output path. The path can either a local file path or an S3 path.
+// output path. The path can either a local file path, an S3 path or an SFTP path. Local and
+// SFTP writes go to a temporary file that is renamed into place.
 func (c *Client) WriteReader(path string, input io.ReadSeeker) error {
+	return c.writeReaderWithOptions(path, input, nil)
+}
+
+// writeReaderWithOptions implements WriteReader and WriteReaderWithOptions
+func (c *Client) writeReaderWithOptions(path string, input io.ReadSeeker, opts *WriteOptions) error {
 	// return the file pointer to the start before reading from it when writing
 	if offset, err := input.Seek(0, io.SeekStart); err != nil || offset != 0 {
 		return fmt.Errorf("failed to reset the file pointer to 0. offset: %d; error %s", offset, err)
 	}
+	if c.DryRun != nil {
+		size, err := input.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		c.DryRun.record(DryRunEntry{Op: "write", Path: path, Size: size, Options: opts})
+		return nil
+	}
+	if c.Audit == nil {
+		return c.writeReaderWithProgress(path, input, opts)
+	}
+	size, err := input.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hashed := newHashingReadSeeker(input)
+	return c.auditedWithResult(AuditRecord{Op: "write", Path: path, Size: size},
+		func() error { return c.writeReaderWithProgress(path, hashed, opts) },
+		func(record *AuditRecord) { record.Checksum = hashed.checksum(size) })
+}
+
+// writeReaderWithProgress writes the input to the path, reporting progress if the client has a
+// Progress function
+func (c *Client) writeReaderWithProgress(path string, input io.ReadSeeker, opts *WriteOptions) error {
+	var progress *progressTracker
+	if c.Progress != nil {
+		size, err := input.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if _, err := input.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		progress = c.newProgress(size)
+	}
+	err := c.writeReader(path, input, progress, opts)
+	if err == nil {
+		progress.finish()
+	}
+	return err
+}
 
-	if strings.HasPrefix(path, "s3://") {
-		s3Conn, err := c.s3ConnectionInformation(path, c.Region)
+// writeReader implements WriteReader, reporting progress to a non-nil tracker
+func (c *Client) writeReader(path string, input io.ReadSeeker, progress *progressTracker, opts *WriteOptions) error {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return err
+		}
+		if c.ResumableUploadDir != "" {
+			return c.writeToS3Resumable(s3Conn, input, progress, opts)
+		}
+		if progress != nil {
+			input = &progressReadSeeker{ReadSeeker: input, progress: progress}
+		}
+		return writeToS3WithOptions(c.ctx, s3Conn, input, c.disableS3Encryption, opts)
+	}
+	if progress != nil {
+		input = &progressReadSeeker{ReadSeeker: input, progress: progress}
+	}
+	if isHTTPPath(path) {
+		return errHTTPReadOnly("write", path)
+	}
+	if isSFTPPath(path) {
+		sftpConn, err := c.sftpConnectionInformation(path)
 		if err != nil {
 			return err
 		}
-		return writeToS3(c.ctx, s3Conn, input, c.disableS3Encryption)
+		defer sftpConn.Close()
+		return writeToSFTP(sftpConn, c.limitWriter(input, path))
+	}
+	if isArchivePath(path) {
+		return errArchiveReadOnly("write", path)
 	}
-	return writeToLocalFile(path, input)
+	if err := writeToLocalFile(path, c.limitWriter(input, path), c.fileMode(), c.dirMode()); err != nil {
+		return err
+	}
+	return c.writeLocalSidecar(path, opts)
 }
 
 // Delete deletes the object at the specified path. The path can be either
-// a local file path or an S3 path.
+// a local file path, an S3 path or an SFTP path.
 func (c *Client) Delete(path string) error {
-	if strings.HasPrefix(path, "s3://") {
-		s3Conn, err := c.s3ConnectionInformation(path, c.Region)
+	if c.DryRun != nil {
+		c.DryRun.record(DryRunEntry{Op: "delete", Path: path})
+		return nil
+	}
+	return c.audited(AuditRecord{Op: "delete", Path: path}, func() error { return c.delete(path) })
+}
+
+func (c *Client) delete(path string) error {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
 		if err != nil {
 			return err
 		}
 		return deleteS3Object(c.ctx, s3Conn)
 	}
+	if isHTTPPath(path) {
+		return errHTTPReadOnly("delete", path)
+	}
+	if isSFTPPath(path) {
+		sftpConn, err := c.sftpConnectionInformation(path)
+		if err != nil {
+			return err
+		}
+		defer sftpConn.Close()
+		return sftpConn.client.Remove(sftpConn.path)
+	}
+	if isArchivePath(path) {
+		return errArchiveReadOnly("delete", path)
+	}
 	// Local file path
-	return os.Remove(path)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return removeLocalSidecar(path)
 }
 
 // ListFiles lists all the files/directories in the directory. It does not recurse
 func (c *Client) ListFiles(path string) ([]string, error) {
-	if strings.HasPrefix(path, "s3://") {
+	if isS3Path(path) {
 		s3Conn, err := c.s3ConnectionInformation(path, c.Region)
 		if err != nil {
 			return nil, err
 		}
 		return lsS3(c.ctx, s3Conn)
 	}
+	if isHTTPPath(path) {
+		return nil, errHTTPReadOnly("list", path)
+	}
+	if isSFTPPath(path) {
+		sftpConn, err := c.sftpConnectionInformation(path)
+		if err != nil {
+			return nil, err
+		}
+		defer sftpConn.Close()
+		return lsSFTP(sftpConn)
+	}
+	if isArchivePath(path) {
+		return c.lsArchive(path)
+	}
 	return lsLocal(path)
 }
 
 // Exists determines if a path does or does not exist.
 // NOTE: S3 is eventually consistent so keep in mind that there is a delay.
 func (c *Client) Exists(path string) (bool, error) {
-	if strings.HasPrefix(path, "s3://") {
-		s3Conn, err := c.s3ConnectionInformation(path, c.Region)
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
 		if err != nil {
 			return false, err
 		}
 		return existsS3(c.ctx, s3Conn)
 	}
+	if isHTTPPath(path) {
+		return existsHTTP(c.ctx, c.httpClient(), path)
+	}
+	if isSFTPPath(path) {
+		sftpConn, err := c.sftpConnectionInformation(path)
+		if err != nil {
+			return false, err
+		}
+		defer sftpConn.Close()
+		return existsSFTP(sftpConn)
+	}
+	if isArchivePath(path) {
+		return c.existsArchive(path)
+	}
 	return existsLocal(path)
 }
 
+// Stat returns the FileInfo for the specified path. The path can either be a local file path,
+// an S3 path, an HTTP(S) URL or an SFTP path. If nothing exists at the path the returned error wraps
+// fs.ErrNotExist.
+func (c *Client) Stat(path string) (FileInfo, error) {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		return statS3(c.ctx, s3Conn)
+	}
+	if isHTTPPath(path) {
+		return statHTTP(c.ctx, c.httpClient(), path)
+	}
+	if isSFTPPath(path) {
+		sftpConn, err := c.sftpConnectionInformation(path)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		defer sftpConn.Close()
+		return statSFTP(sftpConn)
+	}
+	if isArchivePath(path) {
+		return c.statArchive(path)
+	}
+	return statLocal(path)
+}
+
 // GeneratePresignedURL generates a pre-signed URL for the specified S3 object.
-// The path must be an S3 path (s3://bucket/key). The expiration time determines
-// how long the URL will be valid.
+// The path must be an S3 path (s3://bucket/key); other paths return an error wrapping
+// errors.ErrUnsupported. The expiration time determines how long the URL will be valid.
 func (c *Client) GeneratePresignedURL(path string, expiration time.Duration) (string, error) {
-	if strings.HasPrefix(path, "s3://") {
-		s3Conn, err := c.s3ConnectionInformation(path, c.Region)
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
 		if err != nil {
 			return "", err
 		}
 		return generatePresignedS3URL(c.ctx, s3Conn, expiration)
 	}
-	return "", fmt.Errorf("path is not an S3 path (s3://bucket/key), got: %s", path)
+	return "", errPresignUnsupported(path)
 }
 
 func existsS3(ctx context.Context, s3Conn s3Connection) (bool, error) {
@@ -255,6 +588,31 @@ func existsS3(ctx context.Context, s3Conn s3Connection) (bool, error) {
 	return true, nil
 }
 
+func statS3(ctx context.Context, s3Conn s3Connection) (FileInfo, error) {
+	resp, err := s3Conn.handler.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Key:    aws.String(s3Conn.key),
+	})
+	if err != nil {
+		var notFound *s3Types.NotFound
+		if errors.As(err, &notFound) {
+			return FileInfo{}, &fs.PathError{Op: "stat", Path: s3Conn.path(), Err: fs.ErrNotExist}
+		}
+		return FileInfo{}, err
+	}
+	return FileInfo{
+		Size:               aws.ToInt64(resp.ContentLength),
+		ModTime:            aws.ToTime(resp.LastModified),
+		ETag:               aws.ToString(resp.ETag),
+		ContentType:        aws.ToString(resp.ContentType),
+		ContentDisposition: aws.ToString(resp.ContentDisposition),
+		CacheControl:       aws.ToString(resp.CacheControl),
+		Metadata:           resp.Metadata,
+		StorageClass:       s3StorageClass(resp.StorageClass),
+		VersionID:          aws.ToString(resp.VersionId),
+	}, nil
+}
+
 func existsLocal(path string) (bool, error) {
 	_, err := os.Stat(path)
 	if os.IsNotExist(err) {
@@ -263,6 +621,27 @@ func existsLocal(path string) (bool, error) {
 	return err == nil, err
 }
 
+func statLocal(path string) (FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	sidecar, err := readLocalSidecar(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{
+		Size:               info.Size(),
+		ModTime:            info.ModTime(),
+		ETag:               localETag(info),
+		IsDir:              info.IsDir(),
+		ContentType:        sidecar.ContentType,
+		ContentDisposition: sidecar.ContentDisposition,
+		CacheControl:       sidecar.CacheControl,
+		Metadata:           sidecar.Metadata,
+	}, nil
+}
+
 func lsS3(ctx context.Context, s3Conn s3Connection) ([]string, error) {
 	params := s3.ListObjectsV2Input{
 		Bucket:    aws.String(s3Conn.bucket),
@@ -308,13 +687,114 @@ func lsLocal(path string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	results := make([]string, len(resp))
-	for i, val := range resp {
-		results[i] = val.Name()
+	results := make([]string, 0, len(resp))
+	for _, val := range resp {
+		if !isLocalSidecar(val.Name()) {
+			results = append(results, val.Name())
+		}
 	}
 	return results, nil
 }
 
+// listEntry is a named entry in a directory listing
+type listEntry struct {
+	name string
+	info FileInfo
+}
+
+// listDir lists the entries directly inside the directory at path whose names start with
+// namePrefix. It does not recurse.
+func (c *Client) listDir(path, namePrefix string) ([]listEntry, error) {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ConnectionInformation(path, c.Region)
+		if err != nil {
+			return nil, err
+		}
+		return lsS3Entries(c.ctx, s3Conn, namePrefix)
+	}
+	if isHTTPPath(path) {
+		return nil, errHTTPReadOnly("list", path)
+	}
+	if isSFTPPath(path) {
+		sftpConn, err := c.sftpConnectionInformation(path)
+		if err != nil {
+			return nil, err
+		}
+		defer sftpConn.Close()
+		return lsSFTPEntries(sftpConn, namePrefix)
+	}
+	if isArchivePath(path) {
+		return c.lsArchiveEntries(path, namePrefix)
+	}
+	return lsLocalEntries(path, namePrefix)
+}
+
+// lsS3Entries lists the objects and common prefixes directly under the s3Conn key, treating the
+// key as a directory
+func lsS3Entries(ctx context.Context, s3Conn s3Connection, namePrefix string) ([]listEntry, error) {
+	prefix := s3Conn.key
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	pages, err := s3Conn.handler.ListAllObjects(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s3Conn.bucket),
+		Prefix:    aws.String(prefix + namePrefix),
+		Delimiter: aws.String("/"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var entries []listEntry
+	seen := map[string]bool{}
+	for _, page := range pages {
+		for _, val := range page.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(val.Prefix), prefix), "/")
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			entries = append(entries, listEntry{name, FileInfo{IsDir: true}})
+		}
+		for _, val := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(val.Key), prefix)
+			// Skip the object marking the directory itself
+			if name == "" {
+				continue
+			}
+			entries = append(entries, listEntry{name, FileInfo{
+				Size:    aws.ToInt64(val.Size),
+				ModTime: aws.ToTime(val.LastModified),
+				ETag:    aws.ToString(val.ETag),
+			}})
+		}
+	}
+	return entries, nil
+}
+
+func lsLocalEntries(path, namePrefix string) ([]listEntry, error) {
+	resp, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []listEntry
+	for _, val := range resp {
+		if !strings.HasPrefix(val.Name(), namePrefix) || isLocalSidecar(val.Name()) {
+			continue
+		}
+		info, err := val.Info()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, listEntry{val.Name(), FileInfo{
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			ETag:    localETag(info),
+			IsDir:   info.IsDir(),
+		}})
+	}
+	return entries, nil
+}
+
 // s3FileReader converts an S3Path into an io.ReadCloser
 func s3FileReader(ctx context.Context, s3Conn s3Connection) (io.ReadCloser, error) {
 	params := s3.GetObjectInput{
@@ -323,22 +803,65 @@ func s3FileReader(ctx context.Context, s3Conn s3Connection) (io.ReadCloser, erro
 	}
 	resp, err := s3Conn.handler.GetObject(ctx, &params)
 	if err != nil {
-		return nil, err
+		return nil, s3ReadError(s3Conn, err)
 	}
 	return resp.Body, nil
 }
 
-// writeToS3 uploads the given file to S3
-func writeToS3(ctx context.Context, s3Conn s3Connection, input io.ReadSeeker, disableEncryption bool) error {
-	params := s3.PutObjectInput{
+// s3FileRangeReader returns an io.ReadCloser for a byte range of an S3 object
+func s3FileRangeReader(ctx context.Context, s3Conn s3Connection, offset, length int64) (io.ReadCloser, error) {
+	params := s3.GetObjectInput{
 		Bucket: aws.String(s3Conn.bucket),
 		Key:    aws.String(s3Conn.key),
-		Body:   input,
+		Range:  aws.String(rangeHeader(offset, length)),
+	}
+	resp, err := s3Conn.handler.GetObject(ctx, &params)
+	if err != nil {
+		return nil, s3ReadError(s3Conn, err)
+	}
+	return resp.Body, nil
+}
+
+// rangeHeader formats an HTTP Range header value. A negative length reads until the end.
+func rangeHeader(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// localRangeReader returns an io.ReadCloser for a byte range of a local file
+func localRangeReader(path string, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
 	}
-	if !disableEncryption {
-		params.ServerSideEncryption = aesAlgo
+	if length < 0 {
+		return file, nil
 	}
-	_, err := s3Conn.handler.PutObject(ctx, &params)
+	return readCloser{io.LimitReader(file, length), file}, nil
+}
+
+// readCloser combines a Reader with the Closer of the underlying resource
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// writeToS3 uploads the given file to S3
+func writeToS3(ctx context.Context, s3Conn s3Connection, input io.ReadSeeker, disableEncryption bool) error {
+	return writeToS3WithOptions(ctx, s3Conn, input, disableEncryption, nil)
+}
+
+// writeToS3WithOptions uploads the given file to S3 with the headers and metadata of opts
+func writeToS3WithOptions(ctx context.Context, s3Conn s3Connection, input io.ReadSeeker, disableEncryption bool, opts *WriteOptions) error {
+	params := newS3PutObjectInput(s3Conn, input, disableEncryption)
+	opts.applyToPutObject(params)
+	_, err := s3Conn.handler.PutObject(ctx, params)
 	return err
 }
 
@@ -350,7 +873,10 @@ func deleteS3Object(ctx context.Context, s3Conn s3Connection) error {
 	}
 
 	_, err := s3Conn.handler.DeleteObject(ctx, &params)
-	return err
+	if err != nil {
+		return s3DeleteError(ctx, s3Conn, err)
+	}
+	return nil
 }
 
 // generatePresignedS3URL generates a pre-signed URL for the specified S3 object
@@ -358,31 +884,79 @@ func generatePresignedS3URL(ctx context.Context, s3Conn s3Connection, expiration
 	return s3Conn.handler.GeneratePresignedURL(ctx, s3Conn.bucket, s3Conn.key, expiration)
 }
 
-// writeToLocalFile writes the given file locally
-func writeToLocalFile(path string, input io.ReadSeeker) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+// writeToLocalFile writes the given file locally. The input is written to a temporary file in
+// the same directory, which is synced and renamed over the destination, so readers see either
+// the previous file or the complete new one.
+func writeToLocalFile(path string, input io.Reader, fileMode, dirMode os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
 		return err
 	}
-	file, err := os.Create(path)
-	defer file.Close()
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
 	if err != nil {
 		return err
 	}
-	_, err = io.Copy(file, input)
-	return err
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	if err := file.Chmod(fileMode); err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, input); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes a rename in dir durable. It is best effort, since not every platform supports
+// syncing directories.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// fileMode returns the permission of local files written by the client
+func (c *Client) fileMode() os.FileMode {
+	if c.FileMode == 0 {
+		return defaultFileMode
+	}
+	return c.FileMode
+}
+
+// dirMode returns the permission of local directories created by the client
+func (c *Client) dirMode() os.FileMode {
+	if c.DirMode == 0 {
+		return defaultDirMode
+	}
+	return c.DirMode
 }
 
-// parseS3path parses an S3 path (s3://bucket/key) and returns a bucket, key, error tuple
+// parseS3path parses an S3 path (s3://bucket/key) and returns a bucket, key, error tuple. The key
+// is empty for paths that address the whole bucket.
 func parseS3Path(path string) (string, string, error) {
-	// S3 path names are of the form s3://bucket/key
-	stringsArray := strings.SplitN(path, "/", 4)
-	if len(stringsArray) < 4 {
+	p, err := parsePath(path)
+	if err != nil {
+		return "", "", err
+	}
+	if p.Scheme() != schemeS3 {
 		return "", "", fmt.Errorf("invalid s3 path %s", path)
 	}
-	bucketName := stringsArray[2]
-	// Everything after the third slash is the key
-	key := stringsArray[3]
-	return bucketName, key, nil
+	return p.Bucket(), p.Key(), nil
 }
 
 // s3ConnectionInformation parses the s3 path and returns the s3 connection from the
@@ -392,6 +966,9 @@ func (c *Client) s3ConnectionInformation(path, region string) (s3Connection, err
 	if err != nil {
 		return s3Connection{}, err
 	}
+	if c.handler != nil {
+		return s3Connection{c.limitS3Handler(c.handler, "s3://"+bucket+"/"+key), bucket, key}, nil
+	}
 
 	// If no region passed in, look up region in S3
 	if region == "" {
@@ -401,7 +978,16 @@ func (c *Client) s3ConnectionInformation(path, region string) (s3Connection, err
 		}
 	}
 
-	return s3Connection{c.newS3Handler(c.ctx, region), bucket, key}, nil
+	return s3Connection{c.limitS3Handler(c.newS3Handler(c.ctx, region), "s3://"+bucket+"/"+key), bucket, key}, nil
+}
+
+// s3ObjectConnectionInformation is s3ConnectionInformation for paths that must address an
+// object rather than a whole bucket
+func (c *Client) s3ObjectConnectionInformation(path, region string) (s3Connection, error) {
+	if _, key, err := parseS3Path(path); err == nil && key == "" {
+		return s3Connection{}, fmt.Errorf("invalid s3 path %s: missing key", path)
+	}
+	return c.s3ConnectionInformation(path, region)
 }
 
 // getRegionForBucket looks up the region name for the given bucket
@@ -469,6 +1055,50 @@ func (m *liveS3Handler) HeadObject(ctx context.Context, input *s3.HeadObjectInpu
 	return m.liveS3.HeadObject(ctx, input)
 }
 
+func (m *liveS3Handler) CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	return m.liveS3.CreateMultipartUpload(ctx, input)
+}
+
+func (m *liveS3Handler) UploadPart(ctx context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	return m.liveS3.UploadPart(ctx, input)
+}
+
+func (m *liveS3Handler) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	return m.liveS3.CompleteMultipartUpload(ctx, input)
+}
+
+func (m *liveS3Handler) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	return m.liveS3.AbortMultipartUpload(ctx, input)
+}
+
+func (m *liveS3Handler) ListParts(ctx context.Context, input *s3.ListPartsInput) (*s3.ListPartsOutput, error) {
+	return m.liveS3.ListParts(ctx, input)
+}
+
+func (m *liveS3Handler) ListMultipartUploads(ctx context.Context, input *s3.ListMultipartUploadsInput) (*s3.ListMultipartUploadsOutput, error) {
+	return m.liveS3.ListMultipartUploads(ctx, input)
+}
+
+func (m *liveS3Handler) CopyObject(ctx context.Context, input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	return m.liveS3.CopyObject(ctx, input)
+}
+
+func (m *liveS3Handler) PutObjectTagging(ctx context.Context, input *s3.PutObjectTaggingInput) (*s3.PutObjectTaggingOutput, error) {
+	return m.liveS3.PutObjectTagging(ctx, input)
+}
+
+func (m *liveS3Handler) GetObjectTagging(ctx context.Context, input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error) {
+	return m.liveS3.GetObjectTagging(ctx, input)
+}
+
+func (m *liveS3Handler) RestoreObject(ctx context.Context, input *s3.RestoreObjectInput) (*s3.RestoreObjectOutput, error) {
+	return m.liveS3.RestoreObject(ctx, input)
+}
+
+func (m *liveS3Handler) PutObjectLegalHold(ctx context.Context, input *s3.PutObjectLegalHoldInput) (*s3.PutObjectLegalHoldOutput, error) {
+	return m.liveS3.PutObjectLegalHold(ctx, input)
+}
+
 func (m *liveS3Handler) GeneratePresignedURL(ctx context.Context, bucket, key string, expiration time.Duration) (string, error) {
 	if m.s3Client == nil {
 		return "", fmt.Errorf("S3 client not available for presigned URL generation")
@@ -489,6 +1119,30 @@ func (m *liveS3Handler) GeneratePresignedURL(ctx context.Context, bucket, key st
 	return request.URL, nil
 }
 
+func (m *liveS3Handler) PresignGetObject(ctx context.Context, input *s3.GetObjectInput, expiration time.Duration) (*v4.PresignedHTTPRequest, error) {
+	if m.s3Client == nil {
+		return nil, fmt.Errorf("S3 client not available for presigned URL generation")
+	}
+	return s3.NewPresignClient(m.s3Client).PresignGetObject(ctx, input, s3.WithPresignExpires(expiration))
+}
+
+func (m *liveS3Handler) PresignPutObject(ctx context.Context, input *s3.PutObjectInput, expiration time.Duration) (*v4.PresignedHTTPRequest, error) {
+	if m.s3Client == nil {
+		return nil, fmt.Errorf("S3 client not available for presigned URL generation")
+	}
+	return s3.NewPresignClient(m.s3Client).PresignPutObject(ctx, input, s3.WithPresignExpires(expiration))
+}
+
+func (m *liveS3Handler) PresignPostObject(ctx context.Context, input *s3.PutObjectInput, expiration time.Duration, conditions []interface{}) (*s3.PresignedPostRequest, error) {
+	if m.s3Client == nil {
+		return nil, fmt.Errorf("S3 client not available for presigned URL generation")
+	}
+	return s3.NewPresignClient(m.s3Client).PresignPostObject(ctx, input, func(opts *s3.PresignPostOptions) {
+		opts.Expires = expiration
+		opts.Conditions = conditions
+	})
+}
+
 func (c *Client) newS3Handler(ctx context.Context, region string) *liveS3Handler {
 	if c.providedConfig != nil {
 		s3Client := s3.NewFromConfig(*c.providedConfig, func(o *s3.Options) {
@@ -7,7 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -29,14 +31,30 @@ func TestParseS3Path(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, bucketName, "clever-files")
 	assert.Equal(t, s3path, "directory")
+
+	bucketName, s3path, err = parseS3Path("s3://ag-ge")
+	assert.Nil(t, err)
+	assert.Equal(t, bucketName, "ag-ge")
+	assert.Equal(t, s3path, "")
+
+	bucketName, s3path, err = parseS3Path("S3://clever-files//directory/path?versionId=1")
+	assert.Nil(t, err)
+	assert.Equal(t, bucketName, "clever-files")
+	assert.Equal(t, s3path, "directory/path?versionId=1")
 }
 
 func TestParseInvalidS3Path(t *testing.T) {
 	_, _, err := parseS3Path("s3://")
-	assert.EqualError(t, err, "invalid s3 path s3://")
+	assert.EqualError(t, err, "invalid s3 path s3://: missing bucket")
 
-	_, _, err = parseS3Path("s3://ag-ge")
-	assert.EqualError(t, err, "invalid s3 path s3://ag-ge")
+	// Legacy bucket names are accepted
+	bucketName, key, err := parseS3Path("s3://Clever_Files/key")
+	assert.Nil(t, err)
+	assert.Equal(t, "Clever_Files", bucketName)
+	assert.Equal(t, "key", key)
+
+	_, _, err = parseS3Path("/local/path")
+	assert.EqualError(t, err, "invalid s3 path /local/path")
 }
 
 func TestFileReader(t *testing.T) {
@@ -64,6 +82,87 @@ func TestWriteToFilePath(t *testing.T) {
 	assert.Equal(t, "testout", string(output))
 }
 
+// failingReader returns an error after reading part of its data
+type failingReader struct {
+	data string
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestWriteToLocalFileIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.csv")
+	assert.Nil(t, os.WriteFile(path, []byte("previous"), 0644))
+
+	err := writeToLocalFile(path, &failingReader{data: "partial"}, defaultFileMode, defaultDirMode)
+	assert.EqualError(t, err, "connection reset")
+	output, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "previous", string(output))
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1, "temporary files should be cleaned up")
+}
+
+func TestWriteToLocalFileModes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "out.csv")
+	client := &Client{ctx: context.Background(), FileMode: 0640, DirMode: 0750}
+
+	assert.Nil(t, client.Write(path, []byte("data")))
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	info, err = os.Stat(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm()&^umask(t))
+
+	assert.Nil(t, (&Client{ctx: context.Background()}).Write(path, []byte("data")))
+	info, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultFileMode, info.Mode().Perm())
+}
+
+// umask returns the bits the process umask removes from new directories
+func umask(t *testing.T) os.FileMode {
+	dir := filepath.Join(t.TempDir(), "umask")
+	assert.Nil(t, os.Mkdir(dir, 0777))
+	info, err := os.Stat(dir)
+	assert.Nil(t, err)
+	return 0777 &^ info.Mode().Perm()
+}
+
+func TestLocalReadRangeAndStat(t *testing.T) {
+	file, err := os.CreateTemp("/tmp", "readRangeTest")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	assert.Nil(t, Write(file.Name(), []byte("hello world")))
+
+	client := &Client{ctx: context.Background()}
+	reader, err := client.ReadRange(file.Name(), 6, 3)
+	assert.Nil(t, err)
+	body, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Nil(t, reader.Close())
+	assert.Equal(t, "wor", string(body))
+
+	info, err := client.Stat(file.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), info.Size)
+	assert.False(t, info.IsDir)
+
+	_, err = client.Stat(file.Name() + "-missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
 func TestDefaultClientHasContext(t *testing.T) {
 	client := DefaultClient.(*Client)
 	assert.NotNil(t, client.ctx, "DefaultClient should have a valid context to prevent panics")
@@ -85,11 +184,18 @@ func TestS3ConnectionInformation(t *testing.T) {
 			expectedBucket: "test-bucket",
 			expectedKey:    "path/to/file.txt",
 		},
+		{
+			desc:           "BucketOnlyS3Path",
+			path:           "s3://test-bucket",
+			region:         "us-west-2",
+			expectedBucket: "test-bucket",
+			expectedKey:    "",
+		},
 		{
 			desc:          "InvalidS3Path",
-			path:          "s3://invalid",
+			path:          "s3:///valid",
 			region:        "",
-			expectedError: "invalid s3 path s3://invalid",
+			expectedError: "invalid s3 path s3:///valid: missing bucket",
 		},
 	}
 
@@ -246,6 +352,54 @@ func TestS3Calls(t *testing.T) {
 				assert.Equal(t, foundErr.Error(), err)
 			},
 		},
+		{
+			desc: "S3FileRangeReaderSuccess",
+			testCase: func(svc *Mocks3Handler, t *testing.T) {
+				bucket, key, value := "bucket", "key", "lue"
+				output := s3.GetObjectOutput{Body: io.NopCloser(bytes.NewBufferString(value))}
+				params := s3.GetObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String(key),
+					Range:  aws.String("bytes=2-4"),
+				}
+				svc.EXPECT().GetObject(gomock.Any(), &params).Return(&output, nil)
+				foundReader, err := s3FileRangeReader(context.TODO(), s3Connection{svc, bucket, key}, 2, 3)
+				assert.NoError(t, err)
+				body, err := io.ReadAll(foundReader)
+				assert.NoError(t, err)
+				assert.Equal(t, value, string(body))
+			},
+		},
+		{
+			desc: "S3StatSuccess",
+			testCase: func(svc *Mocks3Handler, t *testing.T) {
+				bucket, key := "bucket", "key"
+				modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+				output := s3.HeadObjectOutput{
+					ContentLength: aws.Int64(5),
+					LastModified:  aws.Time(modTime),
+					ETag:          aws.String(`"etag"`),
+					ContentType:   aws.String("text/plain"),
+				}
+				params := s3.HeadObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String(key),
+				}
+				svc.EXPECT().HeadObject(gomock.Any(), &params).Return(&output, nil)
+				info, err := statS3(context.TODO(), s3Connection{svc, bucket, key})
+				assert.NoError(t, err)
+				assert.Equal(t, FileInfo{Size: 5, ModTime: modTime, ETag: `"etag"`, ContentType: "text/plain", StorageClass: "STANDARD"}, info)
+			},
+		},
+		{
+			desc: "S3StatNotFound",
+			testCase: func(svc *Mocks3Handler, t *testing.T) {
+				bucket, key := "bucket", "key"
+				svc.EXPECT().HeadObject(gomock.Any(), gomock.Any()).Return(nil, &s3Types.NotFound{})
+				_, err := statS3(context.TODO(), s3Connection{svc, bucket, key})
+				assert.True(t, errors.Is(err, fs.ErrNotExist))
+			},
+		},
 		{
 			desc: "S3FileWriterSuccess",
 			testCase: func(svc *Mocks3Handler, t *testing.T) {
@@ -0,0 +1,301 @@
+package pathio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrPermissionDenied is returned by a GuardedClient for paths its AccessPolicy doesn't allow.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// AccessPolicy restricts the paths a GuardedClient can access.
+type AccessPolicy struct {
+	// Roots are the local directories, S3 prefixes and URLs the client can access, such as
+	// "s3://exports/district-42/" or "/tmp/work". A root allows itself and the paths under it,
+	// by path segment, so s3://exports/district-42 doesn't allow s3://exports/district-420.
+	// No roots allow every path.
+	Roots []string
+	// ReadOnly rejects writes and deletes.
+	ReadOnly bool
+}
+
+// GuardedClient checks each path against an AccessPolicy before passing the call to a Client,
+// so the checks happen before any network call. Paths are normalized first: local paths are
+// made absolute with symlinks resolved, and S3 and URL paths with . or .. segments are denied,
+// since S3 doesn't resolve them. GuardedClient only has the methods below, and doesn't expose
+// the Client it wraps.
+type GuardedClient struct {
+	client *Client
+	policy AccessPolicy
+	roots  []Path
+}
+
+// NewGuardedClient returns a GuardedClient that accesses paths allowed by policy through
+// client.
+func NewGuardedClient(client *Client, policy AccessPolicy) (*GuardedClient, error) {
+	gc := &GuardedClient{client: client, policy: policy}
+	for _, root := range policy.Roots {
+		p, err := normalizePolicyPath(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access policy root %s: %s", root, err)
+		}
+		gc.roots = append(gc.roots, p)
+	}
+	return gc, nil
+}
+
+// checkRead returns an error wrapping ErrPermissionDenied unless the path is under a root
+func (gc *GuardedClient) checkRead(path string) error {
+	if isArchivePath(path) {
+		// The archive is read from its own path
+		_, archive, _, err := parseArchivePath(path)
+		if err != nil {
+			return err
+		}
+		path = archive
+	}
+	p, err := normalizePolicyPath(path)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, err)
+	}
+	if len(gc.roots) == 0 {
+		return nil
+	}
+	for _, root := range gc.roots {
+		if policyPathWithin(root, p) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is outside the allowed roots", ErrPermissionDenied, path)
+}
+
+// checkWrite returns an error wrapping ErrPermissionDenied if the client is read-only or the
+// path is outside the roots
+func (gc *GuardedClient) checkWrite(op, path string) error {
+	if gc.policy.ReadOnly {
+		return fmt.Errorf("%w: cannot %s %s, the client is read-only", ErrPermissionDenied, op, path)
+	}
+	return gc.checkRead(path)
+}
+
+// normalizePolicyPath parses a path for policy checks
+func normalizePolicyPath(path string) (Path, error) {
+	p, err := parsePath(path)
+	if err != nil {
+		return Path{}, err
+	}
+	if p.scheme != schemeFile {
+		for _, segment := range strings.Split(p.key, "/") {
+			if segment == "." || segment == ".." {
+				return Path{}, fmt.Errorf("path %s has a %s segment", path, segment)
+			}
+		}
+		return p, nil
+	}
+	abs, err := filepath.Abs(p.key)
+	if err != nil {
+		return Path{}, err
+	}
+	p.key, err = resolveExistingSymlinks(abs)
+	return p, err
+}
+
+// resolveExistingSymlinks resolves the symlinks of the longest part of a clean absolute path
+// that exists, so a symlink can't point a path outside its root
+func resolveExistingSymlinks(path string) (string, error) {
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, missing...)...), nil
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
+// policyPathWithin reports whether the normalized path p is root or under it
+func policyPathWithin(root, p Path) bool {
+	if root.scheme != p.scheme || root.host != p.host {
+		return false
+	}
+	if p.scheme == schemeFile {
+		rel, err := filepath.Rel(root.key, p.key)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	rootKey := strings.Trim(root.key, "/")
+	key := strings.Trim(pathpkg.Clean("/"+p.key), "/")
+	return rootKey == "" || key == rootKey || strings.HasPrefix(key, rootKey+"/")
+}
+
+// Reader calls Client.Reader if the path is allowed.
+func (gc *GuardedClient) Reader(path string) (io.ReadCloser, error) {
+	if err := gc.checkRead(path); err != nil {
+		return nil, err
+	}
+	return gc.client.Reader(path)
+}
+
+// ReadRange calls Client.ReadRange if the path is allowed.
+func (gc *GuardedClient) ReadRange(path string, offset, length int64) (io.ReadCloser, error) {
+	if err := gc.checkRead(path); err != nil {
+		return nil, err
+	}
+	return gc.client.ReadRange(path, offset, length)
+}
+
+// Write calls Client.Write if the path is allowed and the client isn't read-only.
+func (gc *GuardedClient) Write(path string, input []byte) error {
+	if err := gc.checkWrite("write", path); err != nil {
+		return err
+	}
+	return gc.client.Write(path, input)
+}
+
+// WriteReader calls Client.WriteReader if the path is allowed and the client isn't read-only.
+func (gc *GuardedClient) WriteReader(path string, input io.ReadSeeker) error {
+	if err := gc.checkWrite("write", path); err != nil {
+		return err
+	}
+	return gc.client.WriteReader(path, input)
+}
+
+// WriteWithOptions calls Client.WriteWithOptions if the path is allowed and the client isn't
+// read-only.
+func (gc *GuardedClient) WriteWithOptions(path string, input []byte, opts WriteOptions) error {
+	if err := gc.checkWrite("write", path); err != nil {
+		return err
+	}
+	return gc.client.WriteWithOptions(path, input, opts)
+}
+
+// WriteReaderWithOptions calls Client.WriteReaderWithOptions if the path is allowed and the
+// client isn't read-only.
+func (gc *GuardedClient) WriteReaderWithOptions(path string, input io.ReadSeeker, opts WriteOptions) error {
+	if err := gc.checkWrite("write", path); err != nil {
+		return err
+	}
+	return gc.client.WriteReaderWithOptions(path, input, opts)
+}
+
+// Delete calls Client.Delete if the path is allowed and the client isn't read-only.
+func (gc *GuardedClient) Delete(path string) error {
+	if err := gc.checkWrite("delete", path); err != nil {
+		return err
+	}
+	return gc.client.Delete(path)
+}
+
+// ListFiles calls Client.ListFiles if the path is allowed. S3 paths are listed by key prefix, so
+// the keys outside the roots, such as district-420/ when listing s3://exports/district-42, are
+// removed from the results.
+func (gc *GuardedClient) ListFiles(path string) ([]string, error) {
+	if err := gc.checkRead(path); err != nil {
+		return nil, err
+	}
+	files, err := gc.client.ListFiles(path)
+	if err != nil || !isS3Path(path) || len(gc.roots) == 0 {
+		return files, err
+	}
+	bucket, _, err := parseS3Path(path)
+	if err != nil {
+		return nil, err
+	}
+	var allowed []string
+	for _, file := range files {
+		key := Path{scheme: schemeS3, host: bucket, key: file}
+		for _, root := range gc.roots {
+			if policyPathWithin(root, key) {
+				allowed = append(allowed, file)
+				break
+			}
+		}
+	}
+	return allowed, nil
+}
+
+// Exists calls Client.Exists if the path is allowed.
+func (gc *GuardedClient) Exists(path string) (bool, error) {
+	if err := gc.checkRead(path); err != nil {
+		return false, err
+	}
+	return gc.client.Exists(path)
+}
+
+// Stat calls Client.Stat if the path is allowed.
+func (gc *GuardedClient) Stat(path string) (FileInfo, error) {
+	if err := gc.checkRead(path); err != nil {
+		return FileInfo{}, err
+	}
+	return gc.client.Stat(path)
+}
+
+// GeneratePresignedURL calls Client.GeneratePresignedURL if the path is allowed.
+func (gc *GuardedClient) GeneratePresignedURL(path string, expiration time.Duration) (string, error) {
+	if err := gc.checkRead(path); err != nil {
+		return "", err
+	}
+	return gc.client.GeneratePresignedURL(path, expiration)
+}
+
+// Copy calls Client.Copy if src is allowed, and dst is allowed and the client isn't read-only.
+func (gc *GuardedClient) Copy(src, dst string) error {
+	if err := gc.checkRead(src); err != nil {
+		return err
+	}
+	if err := gc.checkWrite("write", dst); err != nil {
+		return err
+	}
+	return gc.client.Copy(src, dst)
+}
+
+// Move calls Client.Move if both paths are allowed and the client isn't read-only.
+func (gc *GuardedClient) Move(src, dst string) error {
+	if err := gc.checkWrite("move", src); err != nil {
+		return err
+	}
+	if err := gc.checkWrite("write", dst); err != nil {
+		return err
+	}
+	return gc.client.Move(src, dst)
+}
+
+// Sync calls Client.Sync if src is allowed, and dst is allowed and the client isn't read-only
+// unless opts.DryRun is set.
+func (gc *GuardedClient) Sync(src, dst string, opts SyncOptions) (*SyncResult, error) {
+	if err := gc.checkRead(src); err != nil {
+		return nil, err
+	}
+	check := gc.checkRead(dst)
+	if !opts.DryRun {
+		check = gc.checkWrite("sync to", dst)
+	}
+	if check != nil {
+		return nil, check
+	}
+	return gc.client.Sync(src, dst, opts)
+}
+
+// Diff calls Client.Diff if both paths are allowed.
+func (gc *GuardedClient) Diff(a, b string) (*DiffResult, error) {
+	if err := gc.checkRead(a); err != nil {
+		return nil, err
+	}
+	if err := gc.checkRead(b); err != nil {
+		return nil, err
+	}
+	return gc.client.Diff(a, b)
+}
@@ -0,0 +1,148 @@
+package pathio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardedClientRoots(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	require.NoError(t, os.MkdirAll(work, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644))
+	require.NoError(t, os.Symlink(dir, filepath.Join(work, "escape")))
+
+	gc, err := NewGuardedClient(newFakeS3Client(newFakeS3Handler()), AccessPolicy{
+		Roots: []string{"s3://exports/district-42/", work, "sftp://files.example.com/uploads"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{path: "s3://exports/district-42/students.csv", allowed: true},
+		{path: "S3://exports/district-42/sub/students.csv", allowed: true},
+		{path: "s3://exports/district-42", allowed: true},
+		{path: "s3://exports/district-420/students.csv"},
+		{path: "s3://exports/district-43/students.csv"},
+		{path: "s3://exports/district-42/../district-43/students.csv"},
+		{path: "s3://other/district-42/students.csv"},
+		{path: filepath.Join(work, "out.csv"), allowed: true},
+		{path: "file://" + filepath.Join(work, "sub", "out.csv"), allowed: true},
+		{path: work + "/../secret"},
+		{path: filepath.Join(work, "escape", "secret")},
+		{path: dir},
+		{path: "sftp://files.example.com/uploads/roster.csv", allowed: true},
+		{path: "sftp://files.example.com/uploads/../etc/passwd"},
+		{path: "sftp://other.example.com/uploads/roster.csv"},
+		{path: "https://example.com/file"},
+		{path: "zip+" + filepath.Join(work, "bundle.zip") + "!/a.csv", allowed: true},
+		{path: "zip+" + filepath.Join(dir, "bundle.zip") + "!/a.csv"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			err := gc.checkRead(test.path)
+			if test.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, ErrPermissionDenied), "got %v", err)
+			}
+		})
+	}
+}
+
+func TestGuardedClientOperations(t *testing.T) {
+	work := t.TempDir()
+	c := newFakeS3Client(newFakeS3Handler())
+	require.NoError(t, c.Write("s3://exports/district-42/students.csv", []byte("a,b")))
+	require.NoError(t, c.Write("s3://exports/district-43/students.csv", []byte("c,d")))
+	gc, err := NewGuardedClient(c, AccessPolicy{Roots: []string{"s3://exports/district-42/", work}})
+	require.NoError(t, err)
+
+	body, err := gc.Reader("s3://exports/district-42/students.csv")
+	require.NoError(t, err)
+	body.Close()
+	_, err = gc.Reader("s3://exports/district-43/students.csv")
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+	_, err = gc.ListFiles("s3://exports/")
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+
+	require.NoError(t, gc.Copy("s3://exports/district-42/students.csv", filepath.Join(work, "students.csv")))
+	assert.True(t, errors.Is(gc.Copy("s3://exports/district-43/students.csv", filepath.Join(work, "x.csv")), ErrPermissionDenied))
+	assert.True(t, errors.Is(gc.Copy(filepath.Join(work, "students.csv"), "s3://exports/district-43/students.csv"), ErrPermissionDenied))
+	_, err = gc.Sync("s3://exports/district-42/", filepath.Join(work, "mirror"), SyncOptions{})
+	require.NoError(t, err)
+	_, err = gc.Diff("s3://exports/district-42/", "s3://exports/district-43/")
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+
+	require.NoError(t, gc.Delete(filepath.Join(work, "students.csv")))
+	assert.True(t, errors.Is(gc.Delete("s3://exports/district-43/students.csv"), ErrPermissionDenied))
+	exists, err := c.Exists("s3://exports/district-43/students.csv")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestGuardedClientSiblingPrefix(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	require.NoError(t, c.Write("s3://exports/district-42/students.csv", []byte("a,b")))
+	require.NoError(t, c.Write("s3://exports/district-420/students.csv", []byte("c,d")))
+	gc, err := NewGuardedClient(c, AccessPolicy{Roots: []string{"s3://exports/district-42"}})
+	require.NoError(t, err)
+
+	// The raw key prefix matches district-420/ too, which the roots don't allow
+	files, err := gc.ListFiles("s3://exports/district-42")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"district-42/"}, files)
+	files, err = gc.ListFiles("s3://exports/district-42/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"district-42/students.csv"}, files)
+
+	// Sync and Diff list the tree under the key followed by a slash
+	dst := t.TempDir()
+	gc, err = NewGuardedClient(c, AccessPolicy{Roots: []string{"s3://exports/district-42", dst}})
+	require.NoError(t, err)
+	result, err := gc.Sync("s3://exports/district-42", dst, SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"students.csv"}, result.Copied)
+	diff, err := gc.Diff("s3://exports/district-42", dst)
+	require.NoError(t, err)
+	assert.Empty(t, diff.Added)
+	assert.Empty(t, diff.Removed)
+}
+
+func TestGuardedClientReadOnly(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	require.NoError(t, c.Write("s3://exports/students.csv", []byte("a,b")))
+	gc, err := NewGuardedClient(c, AccessPolicy{ReadOnly: true})
+	require.NoError(t, err)
+
+	exists, err := gc.Exists("s3://exports/students.csv")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	info, err := gc.Stat("s3://exports/students.csv")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), info.Size)
+
+	assert.True(t, errors.Is(gc.Write("s3://exports/students.csv", []byte("x")), ErrPermissionDenied))
+	assert.True(t, errors.Is(gc.WriteWithOptions("s3://exports/new.csv", []byte("x"), WriteOptions{}), ErrPermissionDenied))
+	assert.True(t, errors.Is(gc.Delete("s3://exports/students.csv"), ErrPermissionDenied))
+	assert.True(t, errors.Is(gc.Copy("s3://exports/students.csv", "s3://exports/copy.csv"), ErrPermissionDenied))
+	dst := filepath.Join(t.TempDir(), "mirror")
+	_, err = gc.Sync("s3://exports/", dst, SyncOptions{})
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+	result, err := gc.Sync("s3://exports/", dst, SyncOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"students.csv"}, result.Copied)
+	assert.Equal(t, "a,b", readFakeObject(t, c, "s3://exports/students.csv"))
+}
+
+func TestNewGuardedClientInvalidRoot(t *testing.T) {
+	_, err := NewGuardedClient(newFakeS3Client(newFakeS3Handler()), AccessPolicy{Roots: []string{"ftp://host/dir"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,231 @@
+package pathio
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// PresignGetOptions configures a presigned download URL.
+type PresignGetOptions struct {
+	// Expiration is how long the URL is valid. It defaults to 15 minutes.
+	Expiration time.Duration
+	// ResponseContentDisposition, ResponseContentType and ResponseCacheControl override the
+	// headers S3 responds with, for example to name the downloaded file.
+	ResponseContentDisposition string
+	ResponseContentType        string
+	ResponseCacheControl       string
+	// VersionID selects a version of the object other than the latest.
+	VersionID string
+	// SSECustomerKey is the 256-bit key of an object encrypted with a customer provided key.
+	// The key isn't part of the URL, so the download must send the returned headers.
+	SSECustomerKey []byte
+}
+
+// PresignPutOptions are signed into a presigned PUT URL, so the upload must send the matching
+// headers returned in PresignedRequest.Header.
+type PresignPutOptions struct {
+	ContentType string
+	// ContentLength is the exact size the upload must have, if set. S3 can't enforce a range of
+	// sizes on PUT URLs; use GeneratePresignedPost for that.
+	ContentLength int64
+	// ServerSideEncryption overrides the client's encryption, which is AES256 unless S3
+	// encryption is disabled. SSEKMSKeyID sets the key for aws:kms encryption.
+	ServerSideEncryption string
+	SSEKMSKeyID          string
+}
+
+// PresignedRequest is a presigned URL and the headers that must be sent with it.
+type PresignedRequest struct {
+	URL    string
+	Method string
+	Header http.Header
+}
+
+// PresignPostPolicy sets the conditions of a presigned POST.
+type PresignPostPolicy struct {
+	// Expiration is how long the form can be used. It defaults to 15 minutes.
+	Expiration time.Duration
+	// ContentType is the content type the upload must have, or the prefix it must start with if
+	// it ends with a slash, such as "image/".
+	ContentType string
+	// MinContentLength and MaxContentLength bound the size of the upload when MaxContentLength
+	// is set.
+	MinContentLength int64
+	MaxContentLength int64
+	// ServerSideEncryption overrides the client's encryption, which is AES256 unless S3
+	// encryption is disabled. SSEKMSKeyID sets the key for aws:kms encryption.
+	ServerSideEncryption string
+	SSEKMSKeyID          string
+	// Conditions are added to the policy as is, see
+	// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
+	Conditions []interface{}
+}
+
+// PresignedPost is the URL and form fields of a presigned POST. The fields must be sent as
+// multipart form data before the file field.
+type PresignedPost struct {
+	URL    string
+	Fields map[string]string
+}
+
+// GeneratePresignedGetURL generates a presigned URL that downloads the S3 object with a GET
+// request, with the options signed in. The request must send the returned headers, which are
+// only set for SSE-C objects. Paths other than S3 paths return an error wrapping
+// errors.ErrUnsupported.
+func (c *Client) GeneratePresignedGetURL(path string, opts PresignGetOptions) (*PresignedRequest, error) {
+	if !isS3Path(path) {
+		return nil, errPresignUnsupported(path)
+	}
+	s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+	if err != nil {
+		return nil, err
+	}
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Key:    aws.String(s3Conn.key),
+	}
+	if opts.ResponseContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(opts.ResponseContentDisposition)
+	}
+	if opts.ResponseContentType != "" {
+		input.ResponseContentType = aws.String(opts.ResponseContentType)
+	}
+	if opts.ResponseCacheControl != "" {
+		input.ResponseCacheControl = aws.String(opts.ResponseCacheControl)
+	}
+	if opts.VersionID != "" {
+		input.VersionId = aws.String(opts.VersionID)
+	}
+	if opts.SSECustomerKey != nil {
+		if len(opts.SSECustomerKey) != 32 {
+			return nil, fmt.Errorf("invalid SSE-C key: must be 32 bytes, got %d", len(opts.SSECustomerKey))
+		}
+		sum := md5.Sum(opts.SSECustomerKey)
+		input.SSECustomerAlgorithm = aws.String(aesAlgo)
+		input.SSECustomerKey = aws.String(base64.StdEncoding.EncodeToString(opts.SSECustomerKey))
+		input.SSECustomerKeyMD5 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	req, err := s3Conn.handler.PresignGetObject(c.ctx, input, opts.Expiration)
+	if err != nil {
+		return nil, err
+	}
+	return newPresignedRequest(req), nil
+}
+
+// GeneratePresignedPutURL generates a presigned URL that uploads to the S3 path with a PUT
+// request, valid for expiration. The options are part of the signature, and the request must
+// send the returned headers.
+func (c *Client) GeneratePresignedPutURL(path string, expiration time.Duration, opts PresignPutOptions) (*PresignedRequest, error) {
+	if !isS3Path(path) {
+		return nil, errPresignUnsupported(path)
+	}
+	s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+	if err != nil {
+		return nil, err
+	}
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Key:    aws.String(s3Conn.key),
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.ContentLength > 0 {
+		input.ContentLength = aws.Int64(opts.ContentLength)
+	}
+	input.ServerSideEncryption = c.presignEncryption(opts.ServerSideEncryption)
+	if opts.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(opts.SSEKMSKeyID)
+	}
+	req, err := s3Conn.handler.PresignPutObject(c.ctx, input, expiration)
+	if err != nil {
+		return nil, err
+	}
+	return newPresignedRequest(req), nil
+}
+
+func newPresignedRequest(req *v4.PresignedHTTPRequest) *PresignedRequest {
+	header := req.SignedHeader.Clone()
+	// The HTTP client sets Host from the URL
+	header.Del("Host")
+	return &PresignedRequest{URL: req.URL, Method: req.Method, Header: header}
+}
+
+// GeneratePresignedPost generates a presigned POST that uploads to the S3 path from an HTML
+// form, with the conditions of the policy. If the path ends with a slash, the form can upload
+// any key under it, and the key field defaults to the name of the uploaded file.
+func (c *Client) GeneratePresignedPost(path string, policy PresignPostPolicy) (*PresignedPost, error) {
+	if !isS3Path(path) {
+		return nil, errPresignUnsupported(path)
+	}
+	s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	conditions := append([]interface{}{}, policy.Conditions...)
+	addField := func(name, value string) {
+		fields[name] = value
+		conditions = append(conditions, map[string]string{name: value})
+	}
+
+	key := s3Conn.key
+	if strings.HasSuffix(key, "/") {
+		conditions = append(conditions, []interface{}{"starts-with", "$key", key})
+		key += "${filename}"
+	}
+	if strings.HasSuffix(policy.ContentType, "/") {
+		conditions = append(conditions, []interface{}{"starts-with", "$Content-Type", policy.ContentType})
+	} else if policy.ContentType != "" {
+		addField("Content-Type", policy.ContentType)
+	}
+	if policy.MaxContentLength > 0 {
+		if policy.MinContentLength > policy.MaxContentLength {
+			return nil, fmt.Errorf("invalid content length range %d-%d", policy.MinContentLength, policy.MaxContentLength)
+		}
+		conditions = append(conditions, []interface{}{"content-length-range", policy.MinContentLength, policy.MaxContentLength})
+	}
+	if sse := c.presignEncryption(policy.ServerSideEncryption); sse != "" {
+		addField("x-amz-server-side-encryption", string(sse))
+	}
+	if policy.SSEKMSKeyID != "" {
+		addField("x-amz-server-side-encryption-aws-kms-key-id", policy.SSEKMSKeyID)
+	}
+
+	req, err := s3Conn.handler.PresignPostObject(c.ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Key:    aws.String(key),
+	}, policy.Expiration, conditions)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range req.Values {
+		fields[name] = value
+	}
+	return &PresignedPost{URL: req.URL, Fields: fields}, nil
+}
+
+// presignEncryption returns the server side encryption to sign into a presigned upload
+func (c *Client) presignEncryption(sse string) s3Types.ServerSideEncryption {
+	if sse != "" {
+		return s3Types.ServerSideEncryption(sse)
+	}
+	if c.disableS3Encryption {
+		return ""
+	}
+	return aesAlgo
+}
+
+func errPresignUnsupported(path string) error {
+	return fmt.Errorf("%w: path is not an S3 path (s3://bucket/key), got: %s", errors.ErrUnsupported, path)
+}
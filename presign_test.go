@@ -0,0 +1,163 @@
+package pathio
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPresignTestClient returns a Client that signs with static credentials, which doesn't
+// need the network
+func newPresignTestClient() *Client {
+	c := NewClient(context.Background(), &aws.Config{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		}),
+	})
+	c.Region = "us-east-1"
+	return c
+}
+
+func TestGeneratePresignedGetURL(t *testing.T) {
+	c := newPresignTestClient()
+	key := bytes.Repeat([]byte{7}, 32)
+	req, err := c.GeneratePresignedGetURL("s3://bucket/reports/2024.csv", PresignGetOptions{
+		Expiration:                 15 * time.Minute,
+		ResponseContentDisposition: `attachment; filename="report.csv"`,
+		ResponseContentType:        "text/csv",
+		ResponseCacheControl:       "no-store",
+		VersionID:                  "v2",
+		SSECustomerKey:             key,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "GET", req.Method)
+
+	u, err := url.Parse(req.URL)
+	require.NoError(t, err)
+	query := u.Query()
+	assert.Equal(t, "900", query.Get("X-Amz-Expires"))
+	assert.Equal(t, `attachment; filename="report.csv"`, query.Get("response-content-disposition"))
+	assert.Equal(t, "text/csv", query.Get("response-content-type"))
+	assert.Equal(t, "no-store", query.Get("response-cache-control"))
+	assert.Equal(t, "v2", query.Get("versionId"))
+	// The key is sent in headers rather than in the URL
+	assert.NotContains(t, req.URL, base64.StdEncoding.EncodeToString(key))
+	assert.Equal(t, "AES256", req.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm"))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(key), req.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key"))
+
+	_, err = c.GeneratePresignedGetURL("s3://bucket/key", PresignGetOptions{SSECustomerKey: []byte("short")})
+	assert.Error(t, err)
+}
+
+func TestGeneratePresignedPutURL(t *testing.T) {
+	tests := []struct {
+		desc              string
+		disableEncryption bool
+		opts              PresignPutOptions
+		wantHeader        map[string]string
+		wantSigned        string
+	}{
+		{
+			desc:       "client encryption",
+			wantHeader: map[string]string{"X-Amz-Server-Side-Encryption": "AES256"},
+			wantSigned: "host;x-amz-server-side-encryption",
+		},
+		{
+			desc:              "encryption disabled",
+			disableEncryption: true,
+			wantHeader:        map[string]string{},
+			wantSigned:        "host",
+		},
+		{
+			desc: "content type, length and kms",
+			opts: PresignPutOptions{
+				ContentType:          "text/csv",
+				ContentLength:        1024,
+				ServerSideEncryption: "aws:kms",
+				SSEKMSKeyID:          "key-id",
+			},
+			wantHeader: map[string]string{
+				"Content-Type":                                "text/csv",
+				"Content-Length":                              "1024",
+				"X-Amz-Server-Side-Encryption":                "aws:kms",
+				"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "key-id",
+			},
+			wantSigned: "content-length;content-type;host;x-amz-server-side-encryption;x-amz-server-side-encryption-aws-kms-key-id",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := newPresignTestClient()
+			c.disableS3Encryption = test.disableEncryption
+			req, err := c.GeneratePresignedPutURL("s3://bucket/uploads/roster.csv", 10*time.Minute, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, "PUT", req.Method)
+
+			u, err := url.Parse(req.URL)
+			require.NoError(t, err)
+			assert.Equal(t, "/bucket/uploads/roster.csv", u.Path)
+			assert.Equal(t, "600", u.Query().Get("X-Amz-Expires"))
+			assert.Equal(t, test.wantSigned, u.Query().Get("X-Amz-SignedHeaders"))
+
+			header := map[string]string{}
+			for name := range req.Header {
+				header[name] = req.Header.Get(name)
+			}
+			assert.Equal(t, test.wantHeader, header)
+		})
+	}
+}
+
+func TestGeneratePresignedPost(t *testing.T) {
+	c := newPresignTestClient()
+	post, err := c.GeneratePresignedPost("s3://bucket/uploads/", PresignPostPolicy{
+		Expiration:       time.Hour,
+		ContentType:      "image/",
+		MaxContentLength: 10 << 20,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://s3.us-east-1.amazonaws.com/bucket", post.URL)
+	assert.Equal(t, "uploads/${filename}", post.Fields["key"])
+	assert.Equal(t, "AES256", post.Fields["x-amz-server-side-encryption"])
+	assert.NotEmpty(t, post.Fields["X-Amz-Signature"])
+
+	policyJSON, err := base64.StdEncoding.DecodeString(post.Fields["policy"])
+	require.NoError(t, err)
+	var policy struct {
+		Conditions []interface{} `json:"conditions"`
+	}
+	require.NoError(t, json.Unmarshal(policyJSON, &policy))
+	assert.Contains(t, policy.Conditions, []interface{}{"starts-with", "$key", "uploads/"})
+	assert.Contains(t, policy.Conditions, []interface{}{"starts-with", "$Content-Type", "image/"})
+	assert.Contains(t, policy.Conditions, []interface{}{"content-length-range", float64(0), float64(10 << 20)})
+	assert.Contains(t, policy.Conditions, map[string]interface{}{"x-amz-server-side-encryption": "AES256"})
+
+	post, err = c.GeneratePresignedPost("s3://bucket/uploads/report.csv", PresignPostPolicy{ContentType: "text/csv"})
+	require.NoError(t, err)
+	assert.Equal(t, "uploads/report.csv", post.Fields["key"])
+	assert.Equal(t, "text/csv", post.Fields["Content-Type"])
+}
+
+func TestPresignUnsupported(t *testing.T) {
+	c := newPresignTestClient()
+	_, err := c.GeneratePresignedURL("/tmp/file", time.Minute)
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	_, err = c.GeneratePresignedGetURL("file:///tmp/file", PresignGetOptions{})
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	_, err = c.GeneratePresignedPutURL("/tmp/file", time.Minute, PresignPutOptions{})
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	_, err = c.GeneratePresignedPost("https://example.com/upload", PresignPostPolicy{})
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	_, err = c.GeneratePresignedPost("s3://bucket/key", PresignPostPolicy{MinContentLength: 10, MaxContentLength: 1})
+	assert.Error(t, err)
+}
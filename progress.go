@@ -0,0 +1,151 @@
+package pathio
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultProgressInterval is the minimum time between two calls of a ProgressFunc
+const defaultProgressInterval = 100 * time.Millisecond
+
+// ProgressFunc is called during transfers with the number of bytes transferred so far and the
+// total number of bytes, or -1 if the total is unknown. Calls are serialized, bytesDone never
+// decreases, and a final call is made when the transfer completes.
+type ProgressFunc func(bytesDone, bytesTotal int64)
+
+// progressTracker reports the progress of one transfer to a ProgressFunc. Its methods are safe
+// to call concurrently, and a nil tracker does nothing.
+type progressTracker struct {
+	fn       ProgressFunc
+	interval time.Duration
+	done     atomic.Int64
+	total    atomic.Int64
+
+	mu       sync.Mutex
+	last     time.Time
+	reported int64
+}
+
+// newProgress returns a tracker for a transfer of total bytes, or nil if the client has no
+// ProgressFunc
+func (c *Client) newProgress(total int64) *progressTracker {
+	if c.Progress == nil {
+		return nil
+	}
+	interval := c.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	p := &progressTracker{fn: c.Progress, interval: interval, reported: -1}
+	p.total.Store(total)
+	return p
+}
+
+// setTotal sets the total once it is known
+func (p *progressTracker) setTotal(total int64) {
+	if p != nil {
+		p.total.Store(total)
+	}
+}
+
+// add records n more transferred bytes
+func (p *progressTracker) add(n int64) {
+	if p == nil || n <= 0 {
+		return
+	}
+	p.done.Add(n)
+	p.report(false)
+}
+
+// advanceTo records that the transfer reached position pos. Positions behind the furthest
+// one reached, such as after a retry seeks back, are ignored.
+func (p *progressTracker) advanceTo(pos int64) {
+	if p == nil {
+		return
+	}
+	for {
+		done := p.done.Load()
+		if pos <= done || p.done.CompareAndSwap(done, pos) {
+			break
+		}
+	}
+	p.report(false)
+}
+
+// finish makes the final report of a completed transfer
+func (p *progressTracker) finish() {
+	if p != nil {
+		p.report(true)
+	}
+}
+
+func (p *progressTracker) report(force bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if !force && now.Sub(p.last) < p.interval {
+		return
+	}
+	done, total := p.done.Load(), p.total.Load()
+	if total >= 0 && done > total {
+		// Retried parts are counted twice
+		done = total
+	}
+	if done < p.reported || (done == p.reported && !p.last.IsZero()) {
+		return
+	}
+	p.last, p.reported = now, done
+	p.fn(done, total)
+}
+
+// progressReadCloser reports the bytes read through it, and makes the final report on EOF
+type progressReadCloser struct {
+	io.ReadCloser
+	progress *progressTracker
+}
+
+func (r progressReadCloser) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.progress.add(int64(n))
+	if err == io.EOF {
+		r.progress.finish()
+	}
+	return n, err
+}
+
+// progressReadSeeker reports the furthest position read, so rereading after a seek isn't
+// counted twice
+type progressReadSeeker struct {
+	io.ReadSeeker
+	progress *progressTracker
+	pos      int64
+}
+
+func (r *progressReadSeeker) Read(b []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(b)
+	r.pos += int64(n)
+	r.progress.advanceTo(r.pos)
+	return n, err
+}
+
+func (r *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		r.pos = pos
+	}
+	return pos, err
+}
+
+// progressWriterAt reports the bytes written through it, from any number of goroutines
+type progressWriterAt struct {
+	io.WriterAt
+	progress *progressTracker
+}
+
+func (w progressWriterAt) WriteAt(b []byte, off int64) (int, error) {
+	n, err := w.WriterAt.WriteAt(b, off)
+	w.progress.add(int64(n))
+	return n, err
+}
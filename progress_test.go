@@ -0,0 +1,144 @@
+package pathio
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// progressRecorder is a ProgressFunc that records its calls
+type progressRecorder struct {
+	mu    sync.Mutex
+	calls [][2]int64
+}
+
+func (r *progressRecorder) record(done, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, [2]int64{done, total})
+}
+
+// check asserts that progress never went backwards and ended at done of total
+func (r *progressRecorder) check(t *testing.T, done, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	require.NotEmpty(t, r.calls)
+	for i := 1; i < len(r.calls); i++ {
+		assert.GreaterOrEqual(t, r.calls[i][0], r.calls[i-1][0], "progress went backwards: %v", r.calls)
+	}
+	assert.Equal(t, [2]int64{done, total}, r.calls[len(r.calls)-1])
+}
+
+func TestProgressTrackerThrottlesAndIsMonotonic(t *testing.T) {
+	recorder := &progressRecorder{}
+	c := &Client{Progress: recorder.record, ProgressInterval: time.Hour}
+	progress := c.newProgress(8000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				progress.add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	progress.finish()
+	progress.finish()
+
+	// The first call goes through, the rest are throttled until the final report
+	assert.Len(t, recorder.calls, 2)
+	recorder.check(t, 8000, 8000)
+
+	var nilProgress *progressTracker
+	nilProgress.add(1)
+	nilProgress.finish()
+}
+
+func TestProgressReadSeekerIgnoresRereads(t *testing.T) {
+	recorder := &progressRecorder{}
+	c := &Client{Progress: recorder.record, ProgressInterval: time.Nanosecond}
+	r := &progressReadSeeker{ReadSeeker: strings.NewReader("0123456789"), progress: c.newProgress(10)}
+
+	_, err := io.CopyN(io.Discard, r, 6)
+	require.NoError(t, err)
+	_, err = r.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	r.progress.finish()
+	recorder.check(t, 10, 10)
+}
+
+func TestProgressTransfers(t *testing.T) {
+	body := strings.Repeat("progress", 1000)
+	dir := t.TempDir()
+	local := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(local, []byte(body), 0644))
+
+	newClient := func(recorder *progressRecorder) *Client {
+		handler := newFakeS3Handler()
+		handler.put("bucket", "file", body)
+		return &Client{
+			ctx:                 context.Background(),
+			handler:             handler,
+			Progress:            recorder.record,
+			ProgressInterval:    time.Nanosecond,
+			DownloadPartSize:    1000,
+			DownloadConcurrency: 4,
+			ResumableUploadDir:  t.TempDir(),
+			UploadPartSize:      1000,
+		}
+	}
+	total := int64(len(body))
+
+	t.Run("Reader", func(t *testing.T) {
+		recorder := &progressRecorder{}
+		rc, err := newClient(recorder).Reader("s3://bucket/file")
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		recorder.check(t, total, total)
+	})
+
+	t.Run("WriteReader", func(t *testing.T) {
+		recorder := &progressRecorder{}
+		file, err := os.Open(local)
+		require.NoError(t, err)
+		defer file.Close()
+		require.NoError(t, newClient(recorder).WriteReader(filepath.Join(dir, "written"), file))
+		recorder.check(t, total, total)
+	})
+
+	t.Run("ResumableWriteReader", func(t *testing.T) {
+		recorder := &progressRecorder{}
+		require.NoError(t, newClient(recorder).WriteReader("s3://bucket/upload", strings.NewReader(body)))
+		recorder.check(t, total, total)
+		assert.Greater(t, len(recorder.calls), 2)
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		recorder := &progressRecorder{}
+		_, err := newClient(recorder).Download("s3://bucket/file", manager.NewWriteAtBuffer(nil))
+		require.NoError(t, err)
+		recorder.check(t, total, total)
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		recorder := &progressRecorder{}
+		require.NoError(t, newClient(recorder).Copy("s3://bucket/file", filepath.Join(dir, "copied")))
+		recorder.check(t, total, total)
+	})
+}
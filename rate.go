@@ -0,0 +1,390 @@
+package pathio
+
+import (
+	"context"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit configures token bucket limits on a Client. Zero fields are unlimited.
+type RateLimit struct {
+	// ReadBytesPerSecond limits the bytes read, from S3 and other paths.
+	ReadBytesPerSecond int64
+	// WriteBytesPerSecond limits the bytes written, to S3 and other paths.
+	WriteBytesPerSecond int64
+	// RequestsPerSecond limits the S3 requests made.
+	RequestsPerSecond float64
+}
+
+// rateLimiters are the token buckets of one RateLimit. Unlimited buckets are nil.
+type rateLimiters struct {
+	read, write, requests *rate.Limiter
+}
+
+func newRateLimiters(limit *RateLimit) *rateLimiters {
+	return &rateLimiters{
+		read:     newByteLimiter(limit.ReadBytesPerSecond),
+		write:    newByteLimiter(limit.WriteBytesPerSecond),
+		requests: newRequestLimiter(limit.RequestsPerSecond),
+	}
+}
+
+// newByteLimiter returns a bucket holding up to one second of bytes
+func newByteLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(min(bytesPerSecond, math.MaxInt32)))
+}
+
+func newRequestLimiter(requestsPerSecond float64) *rate.Limiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(requestsPerSecond), int(math.Max(1, math.Ceil(requestsPerSecond))))
+}
+
+type prefixRateLimiters struct {
+	prefix   string
+	limiters *rateLimiters
+}
+
+// clientRateLimiters are the token buckets of a Client, created on first use so they are
+// shared by all of its transfers
+type clientRateLimiters struct {
+	client   *rateLimiters
+	prefixes []prefixRateLimiters
+}
+
+func (c *Client) rateLimiters() *clientRateLimiters {
+	c.rateLimitersOnce.Do(func() {
+		limiters := &clientRateLimiters{}
+		if c.RateLimit != nil {
+			limiters.client = newRateLimiters(c.RateLimit)
+		}
+		for prefix, limit := range c.PrefixRateLimits {
+			limiters.prefixes = append(limiters.prefixes, prefixRateLimiters{prefix, newRateLimiters(limit)})
+		}
+		// Longest prefixes first, so the most specific limit applies
+		sort.Slice(limiters.prefixes, func(i, j int) bool {
+			return len(limiters.prefixes[i].prefix) > len(limiters.prefixes[j].prefix)
+		})
+		c.limiters = limiters
+	})
+	return c.limiters
+}
+
+// limitsFor returns the read, write and request buckets that apply to path: the client's and
+// those of the longest matching prefix
+func (c *Client) limitsFor(path string) (read, write, requests []*rate.Limiter) {
+	limiters := c.rateLimiters()
+	applicable := []*rateLimiters{limiters.client}
+	for _, prefix := range limiters.prefixes {
+		if strings.HasPrefix(path, prefix.prefix) {
+			applicable = append(applicable, prefix.limiters)
+			break
+		}
+	}
+	for _, l := range applicable {
+		if l == nil {
+			continue
+		}
+		if l.read != nil {
+			read = append(read, l.read)
+		}
+		if l.write != nil {
+			write = append(write, l.write)
+		}
+		if l.requests != nil {
+			requests = append(requests, l.requests)
+		}
+	}
+	return read, write, requests
+}
+
+// hasRateLimits reports whether any limit is configured
+func (c *Client) hasRateLimits() bool {
+	return c.RateLimit != nil || len(c.PrefixRateLimits) > 0
+}
+
+// limitS3Handler wraps the handler for an S3 path with the limits that apply to it
+func (c *Client) limitS3Handler(handler s3Handler, path string) s3Handler {
+	if !c.hasRateLimits() {
+		return handler
+	}
+	read, write, requests := c.limitsFor(path)
+	if len(read) == 0 && len(write) == 0 && len(requests) == 0 {
+		return handler
+	}
+	return &rateLimitedS3Handler{next: handler, read: read, write: write, requests: requests}
+}
+
+// limitReader limits the bytes read from a path that isn't on S3
+func (c *Client) limitReader(rc io.ReadCloser, path string) io.ReadCloser {
+	if !c.hasRateLimits() {
+		return rc
+	}
+	read, _, _ := c.limitsFor(path)
+	if len(read) == 0 {
+		return rc
+	}
+	return readCloser{newLimitedReader(c.ctx, rc, read), rc}
+}
+
+// limitWriter limits the bytes written to a path that isn't on S3 by throttling the reads
+// of the input
+func (c *Client) limitWriter(input io.Reader, path string) io.Reader {
+	if !c.hasRateLimits() {
+		return input
+	}
+	_, write, _ := c.limitsFor(path)
+	if len(write) == 0 {
+		return input
+	}
+	return newLimitedReader(c.ctx, input, write)
+}
+
+// waitN takes n tokens from every bucket, in chunks no larger than their burst
+func waitN(ctx context.Context, limiters []*rate.Limiter, n int) error {
+	for _, limiter := range limiters {
+		for remaining := n; remaining > 0; {
+			chunk := min(remaining, limiter.Burst())
+			if err := limiter.WaitN(ctx, chunk); err != nil {
+				return err
+			}
+			remaining -= chunk
+		}
+	}
+	return nil
+}
+
+// limitedReader throttles the bytes read through it. It implements io.Seeker if the
+// underlying reader does, so S3 uploads can still rewind their body.
+type limitedReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*rate.Limiter
+	maxRead  int
+}
+
+type limitedReadSeeker struct {
+	*limitedReader
+}
+
+func (r limitedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.r.(io.Seeker).Seek(offset, whence)
+}
+
+// newLimitedReader wraps r so reading from it takes tokens from the limiters
+func newLimitedReader(ctx context.Context, r io.Reader, limiters []*rate.Limiter) io.Reader {
+	maxRead := math.MaxInt32
+	for _, limiter := range limiters {
+		maxRead = min(maxRead, limiter.Burst())
+	}
+	limited := &limitedReader{ctx: ctx, r: r, limiters: limiters, maxRead: maxRead}
+	if _, ok := r.(io.Seeker); ok {
+		return limitedReadSeeker{limited}
+	}
+	return limited
+}
+
+func (r *limitedReader) Read(b []byte) (int, error) {
+	if len(b) > r.maxRead {
+		b = b[:r.maxRead]
+	}
+	n, err := r.r.Read(b)
+	if n > 0 {
+		if waitErr := waitN(r.ctx, r.limiters, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+// rateLimitedS3Handler enforces request and bandwidth limits on an s3Handler. Every call takes
+// a request token, response bodies of GetObject take read tokens and request bodies of
+// PutObject and UploadPart take write tokens.
+type rateLimitedS3Handler struct {
+	next                  s3Handler
+	read, write, requests []*rate.Limiter
+}
+
+func (h *rateLimitedS3Handler) wait(ctx context.Context) error {
+	return waitN(ctx, h.requests, 1)
+}
+
+func (h *rateLimitedS3Handler) GetBucketLocation(ctx context.Context, input *s3.GetBucketLocationInput) (*s3.GetBucketLocationOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.GetBucketLocation(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) GetObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := h.next.GetObject(ctx, input)
+	if err != nil || len(h.read) == 0 {
+		return resp, err
+	}
+	resp.Body = readCloser{newLimitedReader(ctx, resp.Body, h.read), resp.Body}
+	return resp, nil
+}
+
+func (h *rateLimitedS3Handler) DeleteObject(ctx context.Context, input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.DeleteObject(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) PutObject(ctx context.Context, input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	if len(h.write) > 0 && input.Body != nil {
+		limited := *input
+		limited.Body = newLimitedReader(ctx, input.Body, h.write)
+		input = &limited
+	}
+	return h.next.PutObject(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) ListObjects(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.ListObjects(ctx, input)
+}
+
+// ListAllObjects fetches one page at a time so that every page takes a request token
+func (h *rateLimitedS3Handler) ListAllObjects(ctx context.Context, input *s3.ListObjectsV2Input) ([]*s3.ListObjectsV2Output, error) {
+	params := *input
+	var pages []*s3.ListObjectsV2Output
+	for {
+		page, err := h.ListObjects(ctx, &params)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+		if !aws.ToBool(page.IsTruncated) {
+			return pages, nil
+		}
+		params.ContinuationToken = page.NextContinuationToken
+	}
+}
+
+func (h *rateLimitedS3Handler) HeadObject(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.HeadObject(ctx, input)
+}
+
+// GeneratePresignedURL signs locally, so it doesn't take a request token
+func (h *rateLimitedS3Handler) GeneratePresignedURL(ctx context.Context, bucket, key string, expiration time.Duration) (string, error) {
+	return h.next.GeneratePresignedURL(ctx, bucket, key, expiration)
+}
+
+func (h *rateLimitedS3Handler) PresignGetObject(ctx context.Context, input *s3.GetObjectInput, expiration time.Duration) (*v4.PresignedHTTPRequest, error) {
+	return h.next.PresignGetObject(ctx, input, expiration)
+}
+
+func (h *rateLimitedS3Handler) PresignPutObject(ctx context.Context, input *s3.PutObjectInput, expiration time.Duration) (*v4.PresignedHTTPRequest, error) {
+	return h.next.PresignPutObject(ctx, input, expiration)
+}
+
+func (h *rateLimitedS3Handler) PresignPostObject(ctx context.Context, input *s3.PutObjectInput, expiration time.Duration, conditions []interface{}) (*s3.PresignedPostRequest, error) {
+	return h.next.PresignPostObject(ctx, input, expiration, conditions)
+}
+
+func (h *rateLimitedS3Handler) CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.CreateMultipartUpload(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) UploadPart(ctx context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	if len(h.write) > 0 && input.Body != nil {
+		limited := *input
+		limited.Body = newLimitedReader(ctx, input.Body, h.write)
+		input = &limited
+	}
+	return h.next.UploadPart(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.CompleteMultipartUpload(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.AbortMultipartUpload(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) ListParts(ctx context.Context, input *s3.ListPartsInput) (*s3.ListPartsOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.ListParts(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) ListMultipartUploads(ctx context.Context, input *s3.ListMultipartUploadsInput) (*s3.ListMultipartUploadsOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.ListMultipartUploads(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) CopyObject(ctx context.Context, input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.CopyObject(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) PutObjectTagging(ctx context.Context, input *s3.PutObjectTaggingInput) (*s3.PutObjectTaggingOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.PutObjectTagging(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) GetObjectTagging(ctx context.Context, input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.GetObjectTagging(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) RestoreObject(ctx context.Context, input *s3.RestoreObjectInput) (*s3.RestoreObjectOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.RestoreObject(ctx, input)
+}
+
+func (h *rateLimitedS3Handler) PutObjectLegalHold(ctx context.Context, input *s3.PutObjectLegalHoldInput) (*s3.PutObjectLegalHoldOutput, error) {
+	if err := h.wait(ctx); err != nil {
+		return nil, err
+	}
+	return h.next.PutObjectLegalHold(ctx, input)
+}
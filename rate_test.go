@@ -0,0 +1,95 @@
+package pathio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimitLocalRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	body := bytes.Repeat([]byte("r"), 1500000)
+	require.NoError(t, os.WriteFile(path, body, 0644))
+	c := &Client{ctx: context.Background(), RateLimit: &RateLimit{ReadBytesPerSecond: 1000000}}
+
+	start := time.Now()
+	rc, err := c.Reader(path)
+	require.NoError(t, err)
+	read, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	rc.Close()
+	// The first second of tokens is available immediately, the rest takes half a second
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	assert.Equal(t, body, read)
+}
+
+func TestRateLimitS3Write(t *testing.T) {
+	handler := newFakeS3Handler()
+	c := &Client{ctx: context.Background(), handler: handler, RateLimit: &RateLimit{WriteBytesPerSecond: 1000000}}
+	body := strings.Repeat("w", 1500000)
+
+	start := time.Now()
+	require.NoError(t, c.Write("s3://bucket/file", []byte(body)))
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	assert.Equal(t, body, readFakeObject(t, c, "s3://bucket/file"))
+}
+
+func TestRateLimitS3RequestsPerPrefix(t *testing.T) {
+	handler := newFakeS3Handler()
+	handler.put("bucket", "hot/file", "hot")
+	handler.put("bucket", "cold/file", "cold")
+	c := &Client{
+		ctx:     context.Background(),
+		handler: handler,
+		PrefixRateLimits: map[string]*RateLimit{
+			"s3://bucket/":     {RequestsPerSecond: 1000},
+			"s3://bucket/hot/": {RequestsPerSecond: 20},
+		},
+	}
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		_, err := c.Stat("s3://bucket/cold/file")
+		require.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+
+	start = time.Now()
+	for i := 0; i < 30; i++ {
+		_, err := c.Stat("s3://bucket/hot/file")
+		require.NoError(t, err)
+	}
+	// 20 requests are available immediately, the other 10 take half a second
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	// Only the longest matching prefix applies
+	_, _, requests := c.limitsFor("s3://bucket/hot/file")
+	assert.Len(t, requests, 1)
+	_, _, requests = c.limitsFor("s3://other-bucket/file")
+	assert.Empty(t, requests)
+}
+
+func TestLimitedReaderKeepsSeeker(t *testing.T) {
+	limiters := newRateLimiters(&RateLimit{ReadBytesPerSecond: 2})
+	r := newLimitedReader(context.Background(), strings.NewReader("abc"), []*rate.Limiter{limiters.read})
+	seeker, ok := r.(io.ReadSeeker)
+	require.True(t, ok)
+	buf := make([]byte, 10)
+	n, err := seeker.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n, "reads are capped at the burst size")
+	_, err = seeker.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+
+	_, ok = newLimitedReader(context.Background(), io.MultiReader(), nil).(io.Seeker)
+	assert.False(t, ok)
+}
@@ -0,0 +1,432 @@
+package pathio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReplicationFailed is returned by a ReplicatedClient when a write or delete didn't succeed
+// on enough replicas for its ReplicaPolicy.
+var ErrReplicationFailed = errors.New("replication failed")
+
+// ReplicaPolicy is how many replicas a write or delete of a ReplicatedClient must succeed on.
+type ReplicaPolicy int
+
+const (
+	// ReplicateAll requires every replica to succeed.
+	ReplicateAll ReplicaPolicy = iota
+	// ReplicateQuorum requires a majority of the replicas to succeed, n/2+1 of n. The others
+	// are left to Repair. With a single secondary the majority of the two replicas is both of
+	// them, so it is the same as ReplicateAll.
+	ReplicateQuorum
+)
+
+// ReplicaMapping maps a primary root to the roots of its secondary replicas, such as
+// "s3://exports/" to "s3://exports-dr/". A path under Primary is replicated to the same
+// relative path under each of the Secondaries.
+type ReplicaMapping struct {
+	Primary     string
+	Secondaries []string
+}
+
+// ReplicationOptions configures a ReplicatedClient.
+type ReplicationOptions struct {
+	// Mappings are the replicated roots. Paths outside every primary root are rejected.
+	Mappings []ReplicaMapping
+	Policy   ReplicaPolicy
+}
+
+// RepairOptions configures ReplicatedClient.Repair.
+type RepairOptions struct {
+	// DeleteMissing deletes the files missing from the primary from the secondaries, instead of
+	// copying them back to the primary. Only set it when no write succeeded without the
+	// primary, since a quorum write made while the primary was down is otherwise deleted.
+	DeleteMissing bool
+	// Concurrency is the number of files compared and repaired at once. It defaults to 8.
+	Concurrency int
+}
+
+// RepairResult lists what Repair did, with the paths of the replicas it changed.
+type RepairResult struct {
+	Copied    []string
+	Deleted   []string
+	Unchanged int
+}
+
+// ReplicatedClient is a Pathio that writes and deletes paths under a primary root on every
+// replica of the root in parallel, and reads them from the primary, failing over to the
+// secondaries in order. Paths are always given under the primary root.
+type ReplicatedClient struct {
+	client *Client
+	opts   ReplicationOptions
+}
+
+var _ Pathio = (*ReplicatedClient)(nil)
+
+// NewReplicatedClient returns a ReplicatedClient that accesses the replicas through client.
+func NewReplicatedClient(client *Client, opts ReplicationOptions) (*ReplicatedClient, error) {
+	if len(opts.Mappings) == 0 {
+		return nil, errors.New("invalid replication options: Mappings is required")
+	}
+	for _, mapping := range opts.Mappings {
+		if mapping.Primary == "" || len(mapping.Secondaries) == 0 {
+			return nil, fmt.Errorf("invalid replica mapping for %q: a primary and at least one secondary are required", mapping.Primary)
+		}
+	}
+	return &ReplicatedClient{client: client, opts: opts}, nil
+}
+
+// replicas returns the path on each replica, starting with the primary
+func (r *ReplicatedClient) replicas(path string) ([]string, error) {
+	var match ReplicaMapping
+	var matchRel string
+	found := false
+	for _, mapping := range r.opts.Mappings {
+		rel, ok := relativeToRoot(mapping.Primary, path)
+		if ok && (!found || len(mapping.Primary) > len(match.Primary)) {
+			match, matchRel, found = mapping, rel, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s is not under a replicated root", path)
+	}
+	paths := []string{path}
+	for _, secondary := range match.Secondaries {
+		paths = append(paths, joinReplicaPath(secondary, matchRel))
+	}
+	return paths, nil
+}
+
+// relativeToRoot returns the slash separated path of path relative to root, if it is under it
+func relativeToRoot(root, path string) (string, bool) {
+	if !isS3Path(root) && !isRemoteOnlyPath(root) {
+		rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+		if rel == "." {
+			return "", true
+		}
+		return filepath.ToSlash(rel), true
+	}
+	root = strings.TrimSuffix(root, "/")
+	if path == root || path == root+"/" {
+		return "", true
+	}
+	return strings.CutPrefix(path, root+"/")
+}
+
+// joinReplicaPath joins a relative path to a replica root, which it is when rel is empty
+func joinReplicaPath(root, rel string) string {
+	if rel == "" {
+		return root
+	}
+	return joinTreePath(root, rel)
+}
+
+// required returns the number of replicas out of n that must succeed
+func (r *ReplicatedClient) required(n int) int {
+	if r.opts.Policy == ReplicateQuorum {
+		return n/2 + 1
+	}
+	return n
+}
+
+// fanOut calls fn on the path of every replica in parallel, and returns an error wrapping
+// ErrReplicationFailed if not enough of them succeeded
+func (r *ReplicatedClient) fanOut(op, path string, fn func(replica string) error) error {
+	replicas, err := r.replicas(path)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(replicas))
+	var wg sync.WaitGroup
+	for i, replica := range replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(replica)
+		}()
+	}
+	wg.Wait()
+
+	var failures []string
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", replicas[i], err))
+		}
+	}
+	succeeded, required := len(replicas)-len(failures), r.required(len(replicas))
+	if succeeded >= required {
+		return nil
+	}
+	return fmt.Errorf("%w: %s of %s succeeded on %d of %d replicas, %d required: %s",
+		ErrReplicationFailed, op, path, succeeded, len(replicas), required, strings.Join(failures, "; "))
+}
+
+// failover calls fn on the path of each replica in turn until it succeeds, and returns the
+// error of the primary if it never does
+func (r *ReplicatedClient) failover(path string, fn func(i int, replica string) error) error {
+	replicas, err := r.replicas(path)
+	if err != nil {
+		return err
+	}
+	var primaryErr error
+	for i, replica := range replicas {
+		err := fn(i, replica)
+		if err == nil {
+			return nil
+		}
+		if i == 0 {
+			primaryErr = err
+		}
+	}
+	return primaryErr
+}
+
+// Reader returns an io.ReadCloser for the path from the first replica that can read it,
+// starting with the primary. It is the caller's responsibility to close rc.
+func (r *ReplicatedClient) Reader(path string) (rc io.ReadCloser, err error) {
+	err = r.failover(path, func(_ int, replica string) error {
+		rc, err = r.client.Reader(replica)
+		return err
+	})
+	return rc, err
+}
+
+// Write writes a byte array to the path on every replica.
+func (r *ReplicatedClient) Write(path string, input []byte) error {
+	return r.fanOut("write", path, func(replica string) error {
+		return r.client.Write(replica, input)
+	})
+}
+
+// WriteReader writes all the data read from the io.ReadSeeker to the path on every replica.
+// Inputs that are an io.ReaderAt, such as files, are read by each replica separately; other
+// inputs are copied to a temporary file first, so they don't need to fit in memory.
+func (r *ReplicatedClient) WriteReader(path string, input io.ReadSeeker) error {
+	if offset, err := input.Seek(0, io.SeekStart); err != nil || offset != 0 {
+		return fmt.Errorf("failed to reset the file pointer to 0. offset: %d; error %s", offset, err)
+	}
+	readerAt, ok := input.(io.ReaderAt)
+	if !ok {
+		file, err := os.CreateTemp("", "pathio-replicate-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		if _, err := io.Copy(file, input); err != nil {
+			return err
+		}
+		readerAt, input = file, file
+	}
+	size, err := input.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	return r.fanOut("write", path, func(replica string) error {
+		return r.client.WriteReader(replica, io.NewSectionReader(readerAt, 0, size))
+	})
+}
+
+// Delete deletes the path on every replica. A replica that doesn't have the path counts as a
+// success, unless none of them have it.
+func (r *ReplicatedClient) Delete(path string) error {
+	var mu sync.Mutex
+	var missing []error
+	err := r.fanOut("delete", path, func(replica string) error {
+		err := r.client.Delete(replica)
+		if isNotExist(err) {
+			mu.Lock()
+			defer mu.Unlock()
+			missing = append(missing, err)
+			return nil
+		}
+		return err
+	})
+	replicas, _ := r.replicas(path)
+	if err == nil && len(missing) == len(replicas) {
+		return missing[0]
+	}
+	return err
+}
+
+// ListFiles lists the files in the directory from the first replica that can list it, starting
+// with the primary. S3 keys listed from a secondary are returned under the primary root.
+func (r *ReplicatedClient) ListFiles(path string) (files []string, err error) {
+	err = r.failover(path, func(i int, replica string) error {
+		files, err = r.client.ListFiles(replica)
+		if err == nil && i > 0 && isS3Path(path) && isS3Path(replica) {
+			files, err = rebaseS3Keys(files, replica, path)
+		}
+		return err
+	})
+	return files, err
+}
+
+// rebaseS3Keys replaces the key of the from path, which starts every listed key, by the key of
+// the to path
+func rebaseS3Keys(keys []string, from, to string) ([]string, error) {
+	fromPath, err := parsePath(from)
+	if err != nil {
+		return nil, err
+	}
+	toPath, err := parsePath(to)
+	if err != nil {
+		return nil, err
+	}
+	rebased := make([]string, len(keys))
+	for i, key := range keys {
+		rebased[i] = toPath.key + strings.TrimPrefix(key, fromPath.key)
+	}
+	return rebased, nil
+}
+
+// Exists reports whether the path exists on any replica, checking them in order. It only
+// returns an error if no replica could be checked.
+func (r *ReplicatedClient) Exists(path string) (bool, error) {
+	replicas, err := r.replicas(path)
+	if err != nil {
+		return false, err
+	}
+	var primaryErr error
+	checked := false
+	for i, replica := range replicas {
+		exists, err := r.client.Exists(replica)
+		if err != nil {
+			if i == 0 {
+				primaryErr = err
+			}
+			continue
+		}
+		if exists {
+			return true, nil
+		}
+		checked = true
+	}
+	if checked {
+		return false, nil
+	}
+	return false, primaryErr
+}
+
+// GeneratePresignedURL generates a URL for the path on the first replica that can sign it,
+// starting with the primary.
+func (r *ReplicatedClient) GeneratePresignedURL(path string, expiration time.Duration) (url string, err error) {
+	err = r.failover(path, func(_ int, replica string) error {
+		url, err = r.client.GeneratePresignedURL(replica, expiration)
+		return err
+	})
+	return url, err
+}
+
+// Repair reconciles the replicas of the tree under root, a path under a primary root. Files
+// whose content differs between replicas, by size and checksum, are replaced by the most
+// recently modified version, preferring the primary's on ties. Files are copied to the replicas
+// missing them, including the primary, which recovers writes that reached a quorum while it was
+// down but also brings back files whose delete missed a replica. With opts.DeleteMissing, files
+// the primary doesn't have are deleted from the secondaries instead. Errors for individual files
+// are joined in the returned error, along with the result of what was done.
+func (r *ReplicatedClient) Repair(root string, opts RepairOptions) (*RepairResult, error) {
+	roots, err := r.replicas(root)
+	if err != nil {
+		return nil, err
+	}
+	trees := make([]map[string]*FileInfo, len(roots))
+	rels := map[string]bool{}
+	for i, replicaRoot := range roots {
+		trees[i], err = r.client.listTree(replicaRoot)
+		if err != nil && !isNotExist(err) {
+			return nil, err
+		}
+		if trees[i] == nil {
+			trees[i] = map[string]*FileInfo{}
+		}
+		for rel := range trees[i] {
+			rels[rel] = true
+		}
+	}
+	var sorted []string
+	for rel := range rels {
+		sorted = append(sorted, rel)
+	}
+	sort.Strings(sorted)
+
+	result := &RepairResult{}
+	var mu sync.Mutex
+	var errs []error
+	eachConcurrently(sorted, opts.Concurrency, func(rel string) {
+		paths := make([]string, len(roots))
+		infos := make([]*FileInfo, len(roots))
+		for i, replicaRoot := range roots {
+			paths[i], infos[i] = joinTreePath(replicaRoot, rel), trees[i][rel]
+		}
+		copied, deleted, fileErrs := r.repairFile(paths, infos, opts)
+		mu.Lock()
+		defer mu.Unlock()
+		result.Copied = append(result.Copied, copied...)
+		result.Deleted = append(result.Deleted, deleted...)
+		errs = append(errs, fileErrs...)
+		if len(copied) == 0 && len(deleted) == 0 && len(fileErrs) == 0 {
+			result.Unchanged++
+		}
+	})
+	sort.Strings(result.Copied)
+	sort.Strings(result.Deleted)
+	return result, errors.Join(errs...)
+}
+
+// repairFile reconciles the replicas of a file, given its path and info on each replica, nil
+// where it is missing
+func (r *ReplicatedClient) repairFile(paths []string, infos []*FileInfo, opts RepairOptions) (copied, deleted []string, errs []error) {
+	if opts.DeleteMissing && infos[0] == nil {
+		for i, info := range infos {
+			if info == nil {
+				continue
+			}
+			if err := r.client.Delete(paths[i]); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete %s: %s", paths[i], err))
+			} else {
+				deleted = append(deleted, paths[i])
+			}
+		}
+		return copied, deleted, errs
+	}
+
+	// The source is the most recently modified version, to the second as S3 keeps it
+	source := -1
+	for i, info := range infos {
+		if info != nil && (source < 0 || info.ModTime.Truncate(time.Second).After(infos[source].ModTime.Truncate(time.Second))) {
+			source = i
+		}
+	}
+	for i, info := range infos {
+		if i == source {
+			continue
+		}
+		if info != nil && info.Size == infos[source].Size {
+			same, err := r.client.sameContent(paths[source], paths[i], *infos[source], *info)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to compare %s to %s: %s", paths[source], paths[i], err))
+				continue
+			}
+			if same {
+				continue
+			}
+		}
+		if err := r.client.Copy(paths[source], paths[i]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to repair %s from %s: %s", paths[i], paths[source], err))
+		} else {
+			copied = append(copied, paths[i])
+		}
+	}
+	return copied, deleted, errs
+}
@@ -0,0 +1,283 @@
+package pathio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outageS3Handler fails every object request to the buckets that are down
+type outageS3Handler struct {
+	*fakeS3Handler
+	down map[string]bool
+}
+
+func (h *outageS3Handler) check(bucket *string) error {
+	if h.down[aws.ToString(bucket)] {
+		return errors.New("service unavailable")
+	}
+	return nil
+}
+
+func (h *outageS3Handler) GetObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if err := h.check(input.Bucket); err != nil {
+		return nil, err
+	}
+	return h.fakeS3Handler.GetObject(ctx, input)
+}
+
+func (h *outageS3Handler) PutObject(ctx context.Context, input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if err := h.check(input.Bucket); err != nil {
+		return nil, err
+	}
+	return h.fakeS3Handler.PutObject(ctx, input)
+}
+
+func (h *outageS3Handler) DeleteObject(ctx context.Context, input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	if err := h.check(input.Bucket); err != nil {
+		return nil, err
+	}
+	return h.fakeS3Handler.DeleteObject(ctx, input)
+}
+
+func (h *outageS3Handler) HeadObject(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	if err := h.check(input.Bucket); err != nil {
+		return nil, err
+	}
+	return h.fakeS3Handler.HeadObject(ctx, input)
+}
+
+func (h *outageS3Handler) ListAllObjects(ctx context.Context, input *s3.ListObjectsV2Input) ([]*s3.ListObjectsV2Output, error) {
+	if err := h.check(input.Bucket); err != nil {
+		return nil, err
+	}
+	return h.fakeS3Handler.ListAllObjects(ctx, input)
+}
+
+func newReplicatedTestClient(t *testing.T, policy ReplicaPolicy) (*ReplicatedClient, *outageS3Handler) {
+	handler := &outageS3Handler{fakeS3Handler: newFakeS3Handler(), down: map[string]bool{}}
+	r, err := NewReplicatedClient(&Client{ctx: context.Background(), handler: handler}, ReplicationOptions{
+		Mappings: []ReplicaMapping{{Primary: "s3://primary/data", Secondaries: []string{"s3://dr1/copy/", "s3://dr2"}}},
+		Policy:   policy,
+	})
+	require.NoError(t, err)
+	return r, handler
+}
+
+func TestNewReplicatedClient(t *testing.T) {
+	for _, opts := range []ReplicationOptions{
+		{},
+		{Mappings: []ReplicaMapping{{Primary: "s3://primary/"}}},
+		{Mappings: []ReplicaMapping{{Secondaries: []string{"s3://dr1/"}}}},
+	} {
+		_, err := NewReplicatedClient(&Client{}, opts)
+		assert.Error(t, err)
+	}
+}
+
+func TestReplicaPaths(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewReplicatedClient(&Client{}, ReplicationOptions{Mappings: []ReplicaMapping{
+		{Primary: "s3://primary/", Secondaries: []string{"s3://dr1/"}},
+		{Primary: "s3://primary/hot", Secondaries: []string{"s3://hot-dr/a", "s3://hot-dr/b"}},
+		{Primary: filepath.Join(dir, "local"), Secondaries: []string{"s3://backup/local"}},
+	}})
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		path     string
+		expected []string
+	}{
+		{"s3://primary/x.csv", []string{"s3://primary/x.csv", "s3://dr1/x.csv"}},
+		{"s3://primary/hotter.csv", []string{"s3://primary/hotter.csv", "s3://dr1/hotter.csv"}},
+		{"s3://primary/hot/x.csv", []string{"s3://primary/hot/x.csv", "s3://hot-dr/a/x.csv", "s3://hot-dr/b/x.csv"}},
+		{filepath.Join(dir, "local", "sub", "x.csv"), []string{filepath.Join(dir, "local", "sub", "x.csv"), "s3://backup/local/sub/x.csv"}},
+		{filepath.Join(dir, "local"), []string{filepath.Join(dir, "local"), "s3://backup/local"}},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			paths, err := r.replicas(test.path)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, paths)
+		})
+	}
+
+	for _, path := range []string{"s3://other/x.csv", "s3://primaryx/x.csv", filepath.Join(dir, "localx")} {
+		_, err := r.replicas(path)
+		assert.ErrorContains(t, err, "is not under a replicated root")
+	}
+}
+
+func TestReplicatedWrite(t *testing.T) {
+	r, handler := newReplicatedTestClient(t, ReplicateAll)
+	require.NoError(t, r.Write("s3://primary/data/a.csv", []byte("a")))
+	require.NoError(t, r.WriteReader("s3://primary/data/b.csv", strings.NewReader("b")))
+	file := filepath.Join(t.TempDir(), "c.csv")
+	require.NoError(t, os.WriteFile(file, []byte("c"), 0600))
+	input, err := os.Open(file)
+	require.NoError(t, err)
+	defer input.Close()
+	require.NoError(t, r.WriteReader("s3://primary/data/c.csv", input))
+	// Inputs that aren't an io.ReaderAt go through a temporary file, removed afterwards
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	require.NoError(t, r.WriteReader("s3://primary/data/d.csv", struct{ io.ReadSeeker }{strings.NewReader("d")}))
+	entries, err := os.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Equal(t, []string{
+		"dr1/copy/a.csv", "dr1/copy/b.csv", "dr1/copy/c.csv", "dr1/copy/d.csv",
+		"dr2/a.csv", "dr2/b.csv", "dr2/c.csv", "dr2/d.csv",
+		"primary/data/a.csv", "primary/data/b.csv", "primary/data/c.csv", "primary/data/d.csv",
+	}, fakeObjectIDs(handler.fakeS3Handler))
+	assert.Equal(t, "c", readFakeObject(t, r.client, "s3://dr2/c.csv"))
+	assert.Equal(t, "d", readFakeObject(t, r.client, "s3://dr1/copy/d.csv"))
+
+	require.NoError(t, r.Delete("s3://primary/data/a.csv"))
+	assert.NotContains(t, fakeObjectIDs(handler.fakeS3Handler), "dr2/a.csv")
+
+	assert.ErrorContains(t, r.Write("s3://elsewhere/a.csv", []byte("a")), "is not under a replicated root")
+}
+
+func TestReplicatedWritePolicies(t *testing.T) {
+	for _, test := range []struct {
+		policy   ReplicaPolicy
+		down     []string
+		expected string
+	}{
+		{ReplicateAll, nil, ""},
+		{ReplicateAll, []string{"dr2"}, "write of s3://primary/data/a.csv succeeded on 2 of 3 replicas, 3 required: s3://dr2/a.csv: service unavailable"},
+		{ReplicateQuorum, []string{"dr2"}, ""},
+		{ReplicateQuorum, []string{"primary"}, ""},
+		{ReplicateQuorum, []string{"primary", "dr2"}, "write of s3://primary/data/a.csv succeeded on 1 of 3 replicas, 2 required"},
+	} {
+		r, handler := newReplicatedTestClient(t, test.policy)
+		for _, bucket := range test.down {
+			handler.down[bucket] = true
+		}
+		err := r.Write("s3://primary/data/a.csv", []byte("a"))
+		if test.expected == "" {
+			assert.NoError(t, err)
+			continue
+		}
+		assert.ErrorIs(t, err, ErrReplicationFailed)
+		assert.ErrorContains(t, err, test.expected)
+	}
+}
+
+func TestReplicatedDeleteMissing(t *testing.T) {
+	dir := t.TempDir()
+	primary, secondary := filepath.Join(dir, "primary"), filepath.Join(dir, "secondary")
+	r, err := NewReplicatedClient(&Client{}, ReplicationOptions{
+		Mappings: []ReplicaMapping{{Primary: primary, Secondaries: []string{secondary}}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(primary, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(primary, "a.csv"), []byte("a"), 0600))
+
+	// The secondary missing the file doesn't fail the delete
+	require.NoError(t, r.Delete(filepath.Join(primary, "a.csv")))
+	assert.True(t, os.IsNotExist(r.Delete(filepath.Join(primary, "a.csv"))))
+}
+
+func TestReplicatedReadFailover(t *testing.T) {
+	r, handler := newReplicatedTestClient(t, ReplicateAll)
+	require.NoError(t, r.Write("s3://primary/data/dir/a.csv", []byte("a")))
+	handler.put("dr2", "only-dr2.csv", "dr2")
+
+	handler.down["primary"] = true
+	rc, err := r.Reader("s3://primary/data/dir/a.csv")
+	require.NoError(t, err)
+	body := &bytes.Buffer{}
+	_, err = body.ReadFrom(rc)
+	require.NoError(t, err)
+	rc.Close()
+	assert.Equal(t, "a", body.String())
+
+	files, err := r.ListFiles("s3://primary/data/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data/dir/"}, files)
+
+	exists, err := r.Exists("s3://primary/data/only-dr2.csv")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = r.Exists("s3://primary/data/missing.csv")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// The error of the primary is returned if every replica fails
+	handler.down["dr1"], handler.down["dr2"] = true, true
+	_, err = r.Reader("s3://primary/data/dir/a.csv")
+	assert.ErrorContains(t, err, "service unavailable")
+	_, err = r.Exists("s3://primary/data/dir/a.csv")
+	assert.ErrorContains(t, err, "service unavailable")
+}
+
+func TestRepair(t *testing.T) {
+	r, handler := newReplicatedTestClient(t, ReplicateQuorum)
+	start := time.Now()
+	handler.now = func() time.Time { return start }
+	handler.put("primary", "data/same.csv", "same")
+	handler.put("dr1", "copy/same.csv", "same")
+	handler.put("dr2", "same.csv", "same")
+	// Missing from the primary, after a quorum write
+	handler.put("dr1", "copy/new.csv", "new")
+	handler.put("dr2", "new.csv", "new")
+	// Updated on the secondaries only
+	handler.put("primary", "data/changed.csv", "old")
+	handler.now = func() time.Time { return start.Add(time.Minute) }
+	handler.put("dr1", "copy/changed.csv", "new")
+	handler.put("dr2", "changed.csv", "new")
+
+	result, err := r.Repair("s3://primary/data", RepairOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &RepairResult{
+		Copied:    []string{"s3://primary/data/changed.csv", "s3://primary/data/new.csv"},
+		Unchanged: 1,
+	}, result)
+	assert.Equal(t, "new", readFakeObject(t, r.client, "s3://primary/data/changed.csv"))
+
+	// Repairing again finds no drift
+	result, err = r.Repair("s3://primary/data", RepairOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &RepairResult{Unchanged: 3}, result)
+
+	// With DeleteMissing, files missing from the primary are deleted from the secondaries
+	require.NoError(t, r.client.Delete("s3://primary/data/new.csv"))
+	handler.down["dr2"] = true
+	_, err = r.Repair("s3://primary/data", RepairOptions{DeleteMissing: true})
+	assert.ErrorContains(t, err, "service unavailable")
+	handler.down["dr2"] = false
+	result, err = r.Repair("s3://primary/data", RepairOptions{DeleteMissing: true})
+	require.NoError(t, err)
+	assert.Equal(t, &RepairResult{
+		Deleted:   []string{"s3://dr1/copy/new.csv", "s3://dr2/new.csv"},
+		Unchanged: 2,
+	}, result)
+}
+
+func TestRepairAfterQuorumWrite(t *testing.T) {
+	r, handler := newReplicatedTestClient(t, ReplicateQuorum)
+	handler.down["primary"] = true
+	require.NoError(t, r.Write("s3://primary/data/roster.csv", []byte("roster")))
+	handler.down["primary"] = false
+
+	result, err := r.Repair("s3://primary/data", RepairOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &RepairResult{Copied: []string{"s3://primary/data/roster.csv"}}, result)
+	exists, err := r.Exists("s3://primary/data/roster.csv")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, []string{"dr1/copy/roster.csv", "dr2/roster.csv", "primary/data/roster.csv"},
+		fakeObjectIDs(handler.fakeS3Handler))
+}
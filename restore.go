@@ -0,0 +1,140 @@
+package pathio
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrObjectArchived is returned when reading an S3 object in the GLACIER or DEEP_ARCHIVE storage
+// class, or an archive tier of INTELLIGENT_TIERING, that hasn't been restored. RestoreArchived
+// makes it readable.
+var ErrObjectArchived = errors.New("object is archived")
+
+// RestoreTier sets how fast RestoreArchived restores an object, and what it costs.
+type RestoreTier string
+
+const (
+	// RestoreTierStandard restores in hours, and is the default.
+	RestoreTierStandard RestoreTier = "Standard"
+	// RestoreTierBulk is the cheapest and slowest tier.
+	RestoreTierBulk RestoreTier = "Bulk"
+	// RestoreTierExpedited restores GLACIER objects in minutes, and isn't available for
+	// DEEP_ARCHIVE.
+	RestoreTierExpedited RestoreTier = "Expedited"
+)
+
+// RestoreStatus is the restore state of an S3 object returned by RestoreStatus.
+type RestoreStatus struct {
+	StorageClass string
+	// Archived reports whether the object must be restored before it can be read.
+	Archived bool
+	// InProgress reports whether a restore was requested and hasn't completed.
+	InProgress bool
+	// Expiry is when the restored copy is removed, and is zero unless there is one.
+	Expiry time.Time
+}
+
+// restoreHeaderPattern parses the x-amz-restore header, such as
+// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+var restoreHeaderPattern = regexp.MustCompile(`ongoing-request="(true|false)"(?:, expiry-date="([^"]+)")?`)
+
+// RestoreArchived requests a temporary copy of an archived S3 object, readable for days once the
+// restore completes. Requesting a restore that is in progress does nothing, and requesting one
+// of a restored object extends its expiry. Poll RestoreStatus to know when the object can be
+// read. Paths other than S3 paths return an error wrapping errors.ErrUnsupported.
+func (c *Client) RestoreArchived(path string, days int32, tier RestoreTier) error {
+	if !isS3Path(path) {
+		return errRestoreUnsupported(path)
+	}
+	if days < 1 {
+		return fmt.Errorf("invalid restore days %d: must be at least 1", days)
+	}
+	if tier == "" {
+		tier = RestoreTierStandard
+	}
+	if c.DryRun != nil {
+		c.DryRun.record(DryRunEntry{Op: "restore", Path: path})
+		return nil
+	}
+	return c.audited(AuditRecord{Op: "restore", Path: path}, func() error {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return err
+		}
+		_, err = s3Conn.handler.RestoreObject(c.ctx, &s3.RestoreObjectInput{
+			Bucket: aws.String(s3Conn.bucket),
+			Key:    aws.String(s3Conn.key),
+			RestoreRequest: &s3Types.RestoreRequest{
+				Days:                 aws.Int32(days),
+				GlacierJobParameters: &s3Types.GlacierJobParameters{Tier: s3Types.Tier(tier)},
+			},
+		})
+		if s3ErrorCode(err) == "RestoreAlreadyInProgress" {
+			return nil
+		}
+		return err
+	})
+}
+
+// RestoreStatus returns whether the S3 object at path is archived and the state of its restore.
+// Paths other than S3 paths return an error wrapping errors.ErrUnsupported.
+func (c *Client) RestoreStatus(path string) (*RestoreStatus, error) {
+	if !isS3Path(path) {
+		return nil, errRestoreUnsupported(path)
+	}
+	s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s3Conn.handler.HeadObject(c.ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Key:    aws.String(s3Conn.key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	status := &RestoreStatus{StorageClass: s3StorageClass(resp.StorageClass)}
+	if match := restoreHeaderPattern.FindStringSubmatch(aws.ToString(resp.Restore)); match != nil {
+		status.InProgress = match[1] == "true"
+		if match[2] != "" {
+			expiry, err := http.ParseTime(match[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid restore expiry of %s: %s", path, err)
+			}
+			status.Expiry = expiry
+		}
+	}
+	archiveClass := resp.StorageClass == s3Types.StorageClassGlacier ||
+		resp.StorageClass == s3Types.StorageClassDeepArchive || resp.ArchiveStatus != ""
+	status.Archived = archiveClass && status.Expiry.IsZero()
+	return status, nil
+}
+
+// s3StorageClass returns the storage class of an object, which S3 omits for STANDARD
+func s3StorageClass(class s3Types.StorageClass) string {
+	if class == "" {
+		return string(s3Types.StorageClassStandard)
+	}
+	return string(class)
+}
+
+// s3ReadError returns an error wrapping ErrObjectArchived if a read failed because the object
+// is archived, or else the error as is
+func s3ReadError(s3Conn s3Connection, err error) error {
+	var invalidState *s3Types.InvalidObjectState
+	if errors.As(err, &invalidState) || s3ErrorCode(err) == "InvalidObjectState" {
+		return fmt.Errorf("%w: %s must be restored before it can be read", ErrObjectArchived, s3Conn.path())
+	}
+	return err
+}
+
+func errRestoreUnsupported(path string) error {
+	return fmt.Errorf("%w: restoring archived objects is only supported on S3 paths, got: %s", errors.ErrUnsupported, path)
+}
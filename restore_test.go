@@ -0,0 +1,111 @@
+package pathio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteWithStorageClass(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	require.NoError(t, c.WriteWithOptions("s3://bucket/exports/2024.csv", []byte("a,b"), WriteOptions{StorageClass: "STANDARD_IA"}))
+	info, err := c.Stat("s3://bucket/exports/2024.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "STANDARD_IA", info.StorageClass)
+
+	require.NoError(t, c.Write("s3://bucket/exports/2025.csv", []byte("a,b")))
+	info, err = c.Stat("s3://bucket/exports/2025.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "STANDARD", info.StorageClass)
+}
+
+func TestRestoreArchived(t *testing.T) {
+	handler := newFakeS3Handler()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+	c := newFakeS3Client(handler)
+	path := "s3://bucket/archive/2019.csv"
+	require.NoError(t, c.WriteWithOptions(path, []byte("old"), WriteOptions{StorageClass: "GLACIER"}))
+
+	_, err := c.Reader(path)
+	assert.True(t, errors.Is(err, ErrObjectArchived))
+	_, err = c.ReadRange(path, 0, 1)
+	assert.True(t, errors.Is(err, ErrObjectArchived))
+	status, err := c.RestoreStatus(path)
+	require.NoError(t, err)
+	assert.Equal(t, &RestoreStatus{StorageClass: "GLACIER", Archived: true}, status)
+
+	require.NoError(t, c.RestoreArchived(path, 7, RestoreTierBulk))
+	// Requesting it again while it is in progress does nothing
+	require.NoError(t, c.RestoreArchived(path, 7, RestoreTierBulk))
+	status, err = c.RestoreStatus(path)
+	require.NoError(t, err)
+	assert.Equal(t, &RestoreStatus{StorageClass: "GLACIER", Archived: true, InProgress: true}, status)
+
+	handler.completeRestore("bucket", "archive/2019.csv")
+	status, err = c.RestoreStatus(path)
+	require.NoError(t, err)
+	assert.Equal(t, &RestoreStatus{StorageClass: "GLACIER", Expiry: now.AddDate(0, 0, 7)}, status)
+	assert.Equal(t, "old", readFakeObject(t, c, path))
+
+	// A restored object can be restored again to extend it
+	require.NoError(t, c.RestoreArchived(path, 30, ""))
+	status, err = c.RestoreStatus(path)
+	require.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 30), status.Expiry)
+}
+
+// tieringS3Handler reports objects in an archive tier of INTELLIGENT_TIERING and records restore
+// requests
+type tieringS3Handler struct {
+	*fakeS3Handler
+	restores []*s3.RestoreObjectInput
+}
+
+func (h *tieringS3Handler) HeadObject(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return &s3.HeadObjectOutput{
+		StorageClass:  s3Types.StorageClassIntelligentTiering,
+		ArchiveStatus: s3Types.ArchiveStatusDeepArchiveAccess,
+	}, nil
+}
+
+func (h *tieringS3Handler) RestoreObject(ctx context.Context, input *s3.RestoreObjectInput) (*s3.RestoreObjectOutput, error) {
+	h.restores = append(h.restores, input)
+	return &s3.RestoreObjectOutput{}, nil
+}
+
+func TestRestoreArchivedIntelligentTiering(t *testing.T) {
+	handler := &tieringS3Handler{fakeS3Handler: newFakeS3Handler()}
+	c := &Client{ctx: context.Background(), handler: handler}
+	status, err := c.RestoreStatus("s3://bucket/key")
+	require.NoError(t, err)
+	assert.Equal(t, &RestoreStatus{StorageClass: "INTELLIGENT_TIERING", Archived: true}, status)
+
+	require.NoError(t, c.RestoreArchived("s3://bucket/key", 3, ""))
+	require.Len(t, handler.restores, 1)
+	assert.Equal(t, int32(3), *handler.restores[0].RestoreRequest.Days)
+	assert.Equal(t, s3Types.TierStandard, handler.restores[0].RestoreRequest.GlacierJobParameters.Tier)
+}
+
+func TestRestoreArchivedErrors(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	assert.True(t, errors.Is(c.RestoreArchived("/tmp/file", 1, ""), errors.ErrUnsupported))
+	_, err := c.RestoreStatus("https://example.com/file")
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	assert.Error(t, c.RestoreArchived("s3://bucket/key", 0, ""))
+
+	// Objects that aren't archived can't be restored
+	require.NoError(t, c.Write("s3://bucket/key", []byte("x")))
+	assert.Error(t, c.RestoreArchived("s3://bucket/key", 1, ""))
+
+	// Other read errors are returned as is
+	_, err = c.Reader("s3://bucket/missing")
+	assert.False(t, errors.Is(err, ErrObjectArchived))
+	assert.True(t, isNotExist(err))
+}
@@ -0,0 +1,536 @@
+package pathio
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeS3Handler is an in-memory s3Handler for tests that exercise pathio end to end
+type fakeS3Handler struct {
+	mu      sync.Mutex
+	objects map[string]*fakeS3Object
+	uploads map[string]*fakeS3Upload
+	now     func() time.Time
+}
+
+type fakeS3Upload struct {
+	bucket, key string
+	initiated   time.Time
+	parts       map[int32][]byte
+	headers     fakeS3Headers
+}
+
+type fakeS3Object struct {
+	body    []byte
+	etag    string
+	modTime time.Time
+	headers fakeS3Headers
+	// partSizes are the sizes of the parts of a multipart upload
+	partSizes []int64
+	// restoreDays is the number of days of the restore requested by RestoreObject, which
+	// completeRestore completes
+	restoreDays   int32
+	restoreExpiry time.Time
+}
+
+// fakeS3Headers are the headers, metadata and tags stored with an object
+type fakeS3Headers struct {
+	contentType        *string
+	contentDisposition *string
+	cacheControl       *string
+	metadata           map[string]string
+	tags               map[string]string
+	storageClass       s3Types.StorageClass
+	objectLockMode     s3Types.ObjectLockMode
+	retainUntil        *time.Time
+	legalHold          s3Types.ObjectLockLegalHoldStatus
+}
+
+// fakeS3Tags parses the URL encoded Tagging parameter of a write
+func fakeS3Tags(tagging *string) (map[string]string, error) {
+	values, err := url.ParseQuery(aws.ToString(tagging))
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]string{}
+	for key := range values {
+		tags[key] = values.Get(key)
+	}
+	return tags, nil
+}
+
+func newFakeS3Handler() *fakeS3Handler {
+	return &fakeS3Handler{objects: map[string]*fakeS3Object{}, uploads: map[string]*fakeS3Upload{}, now: time.Now}
+}
+
+// newFakeS3Client returns a Client whose S3 calls are served by the handler
+func newFakeS3Client(handler *fakeS3Handler) *Client {
+	return &Client{ctx: context.Background(), handler: handler}
+}
+
+func fakeS3ObjectID(bucket, key *string) string {
+	return aws.ToString(bucket) + "/" + aws.ToString(key)
+}
+
+// put stores an object directly, bypassing PutObject
+func (f *fakeS3Handler) put(bucket, key, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.store(bucket+"/"+key, []byte(body))
+}
+
+// store saves an object; the caller must hold f.mu
+func (f *fakeS3Handler) store(id string, body []byte) *fakeS3Object {
+	obj := &fakeS3Object{
+		body:    body,
+		etag:    fmt.Sprintf(`"%x"`, md5.Sum(body)),
+		modTime: f.now().UTC().Truncate(time.Second),
+	}
+	f.objects[id] = obj
+	return obj
+}
+
+func (f *fakeS3Handler) GetBucketLocation(ctx context.Context, input *s3.GetBucketLocationInput) (*s3.GetBucketLocationOutput, error) {
+	return &s3.GetBucketLocationOutput{}, nil
+}
+
+func (f *fakeS3Handler) GetObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[fakeS3ObjectID(input.Bucket, input.Key)]
+	if !ok {
+		return nil, &s3Types.NoSuchKey{}
+	}
+	if input.IfNoneMatch != nil && *input.IfNoneMatch == obj.etag {
+		return nil, &smithy.GenericAPIError{Code: "NotModified"}
+	}
+	if input.IfMatch != nil && *input.IfMatch != obj.etag {
+		return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+	}
+	if obj.archived() {
+		return nil, &s3Types.InvalidObjectState{StorageClass: obj.headers.storageClass}
+	}
+	body := obj.body
+	var contentRange *string
+	if input.Range != nil {
+		start, end, _ := strings.Cut(strings.TrimPrefix(*input.Range, "bytes="), "-")
+		first, _ := strconv.Atoi(start)
+		last := len(body) - 1
+		if end != "" {
+			last, _ = strconv.Atoi(end)
+		}
+		first, last = min(first, len(body)), min(last+1, len(body))
+		body = body[first:last]
+		contentRange = aws.String(fmt.Sprintf("bytes %d-%d/%d", first, last-1, len(obj.body)))
+	}
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(strings.NewReader(string(body))),
+		ContentLength: aws.Int64(int64(len(body))),
+		ContentRange:  contentRange,
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.modTime),
+	}, nil
+}
+
+func (f *fakeS3Handler) DeleteObject(ctx context.Context, input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Object Lock buckets are versioned, so deleting without a version ID succeeds even if the
+	// object is locked: the delete marker hides the object and its locked version stays
+	id := fakeS3ObjectID(input.Bucket, input.Key)
+	if input.IfMatch != nil {
+		if obj, ok := f.objects[id]; !ok {
+			return nil, &s3Types.NoSuchKey{}
+		} else if *input.IfMatch != obj.etag {
+			return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+		}
+	}
+	delete(f.objects, id)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (f *fakeS3Handler) PutObject(ctx context.Context, input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := fakeS3Tags(input.Tagging)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := fakeS3ObjectID(input.Bucket, input.Key)
+	existing, ok := f.objects[id]
+	if input.IfNoneMatch != nil && ok {
+		return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+	}
+	if input.IfMatch != nil && !ok {
+		return nil, &s3Types.NoSuchKey{}
+	}
+	if input.IfMatch != nil && *input.IfMatch != existing.etag {
+		return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+	}
+	obj := f.store(id, body)
+	obj.headers = fakeS3Headers{
+		contentType:        input.ContentType,
+		contentDisposition: input.ContentDisposition,
+		cacheControl:       input.CacheControl,
+		metadata:           input.Metadata,
+		tags:               tags,
+		storageClass:       input.StorageClass,
+		objectLockMode:     input.ObjectLockMode,
+		retainUntil:        input.ObjectLockRetainUntilDate,
+	}
+	return &s3.PutObjectOutput{ETag: aws.String(obj.etag)}, nil
+}
+
+func (f *fakeS3Handler) ListObjects(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucketPrefix := aws.ToString(input.Bucket) + "/"
+	prefix, delimiter := aws.ToString(input.Prefix), aws.ToString(input.Delimiter)
+	var keys []string
+	for id := range f.objects {
+		if key, ok := strings.CutPrefix(id, bucketPrefix); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	output := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				commonPrefix := key[:len(prefix)+i+len(delimiter)]
+				if !seen[commonPrefix] {
+					seen[commonPrefix] = true
+					output.CommonPrefixes = append(output.CommonPrefixes, s3Types.CommonPrefix{Prefix: aws.String(commonPrefix)})
+				}
+				continue
+			}
+		}
+		obj := f.objects[bucketPrefix+key]
+		output.Contents = append(output.Contents, s3Types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(int64(len(obj.body))),
+			ETag:         aws.String(obj.etag),
+			LastModified: aws.Time(obj.modTime),
+		})
+	}
+	return output, nil
+}
+
+func (f *fakeS3Handler) ListAllObjects(ctx context.Context, input *s3.ListObjectsV2Input) ([]*s3.ListObjectsV2Output, error) {
+	page, err := f.ListObjects(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return []*s3.ListObjectsV2Output{page}, nil
+}
+
+func (f *fakeS3Handler) HeadObject(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[fakeS3ObjectID(input.Bucket, input.Key)]
+	if !ok {
+		return nil, &s3Types.NotFound{}
+	}
+	size := int64(len(obj.body))
+	var partsCount *int32
+	if part := aws.ToInt32(input.PartNumber); part > 0 {
+		// Objects uploaded in a single part have only part 1
+		switch {
+		case len(obj.partSizes) > 0 && int(part) <= len(obj.partSizes):
+			size, partsCount = obj.partSizes[part-1], aws.Int32(int32(len(obj.partSizes)))
+		case len(obj.partSizes) > 0 || part > 1:
+			return nil, &smithy.GenericAPIError{Code: "InvalidPartNumber"}
+		}
+	}
+	var checksum *string
+	var checksumType s3Types.ChecksumType
+	if input.ChecksumMode == s3Types.ChecksumModeEnabled && len(obj.partSizes) == 0 {
+		// Multipart uploads are stored without a full object checksum, as by older clients
+		hash := crc64.New(crc64.MakeTable(crc64NVME))
+		hash.Write(obj.body)
+		checksum = aws.String(base64.StdEncoding.EncodeToString(hash.Sum(nil)))
+		checksumType = s3Types.ChecksumTypeFullObject
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength:             aws.Int64(size),
+		PartsCount:                partsCount,
+		ChecksumCRC64NVME:         checksum,
+		ChecksumType:              checksumType,
+		ETag:                      aws.String(obj.etag),
+		LastModified:              aws.Time(obj.modTime),
+		ContentType:               obj.headers.contentType,
+		ContentDisposition:        obj.headers.contentDisposition,
+		CacheControl:              obj.headers.cacheControl,
+		Metadata:                  obj.headers.metadata,
+		StorageClass:              obj.headers.storageClass,
+		Restore:                   obj.restoreHeader(),
+		ObjectLockMode:            obj.headers.objectLockMode,
+		ObjectLockRetainUntilDate: obj.headers.retainUntil,
+		ObjectLockLegalHoldStatus: obj.headers.legalHold,
+	}, nil
+}
+
+func (f *fakeS3Handler) GeneratePresignedURL(ctx context.Context, bucket, key string, expiration time.Duration) (string, error) {
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s?X-Amz-Expires=%d", bucket, key, int(expiration.Seconds())), nil
+}
+
+func (f *fakeS3Handler) PresignGetObject(ctx context.Context, input *s3.GetObjectInput, expiration time.Duration) (*v4.PresignedHTTPRequest, error) {
+	url, err := f.GeneratePresignedURL(ctx, aws.ToString(input.Bucket), aws.ToString(input.Key), expiration)
+	return &v4.PresignedHTTPRequest{URL: url, Method: "GET", SignedHeader: http.Header{}}, err
+}
+
+func (f *fakeS3Handler) PresignPutObject(ctx context.Context, input *s3.PutObjectInput, expiration time.Duration) (*v4.PresignedHTTPRequest, error) {
+	url, err := f.GeneratePresignedURL(ctx, aws.ToString(input.Bucket), aws.ToString(input.Key), expiration)
+	return &v4.PresignedHTTPRequest{URL: url, Method: "PUT", SignedHeader: http.Header{}}, err
+}
+
+func (f *fakeS3Handler) PresignPostObject(ctx context.Context, input *s3.PutObjectInput, expiration time.Duration, conditions []interface{}) (*s3.PresignedPostRequest, error) {
+	return &s3.PresignedPostRequest{
+		URL:    fmt.Sprintf("https://%s.s3.amazonaws.com", aws.ToString(input.Bucket)),
+		Values: map[string]string{"key": aws.ToString(input.Key)},
+	}, nil
+}
+
+func (f *fakeS3Handler) CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	tags, err := fakeS3Tags(input.Tagging)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	uploadID := fmt.Sprintf("upload-%d", len(f.uploads)+1)
+	f.uploads[uploadID] = &fakeS3Upload{
+		bucket:    aws.ToString(input.Bucket),
+		key:       aws.ToString(input.Key),
+		initiated: f.now().UTC(),
+		parts:     map[int32][]byte{},
+		headers: fakeS3Headers{
+			contentType:        input.ContentType,
+			contentDisposition: input.ContentDisposition,
+			cacheControl:       input.CacheControl,
+			metadata:           input.Metadata,
+			tags:               tags,
+			storageClass:       input.StorageClass,
+			objectLockMode:     input.ObjectLockMode,
+			retainUntil:        input.ObjectLockRetainUntilDate,
+		},
+	}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(uploadID)}, nil
+}
+
+func (f *fakeS3Handler) UploadPart(ctx context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	body, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	upload, ok := f.uploads[aws.ToString(input.UploadId)]
+	if !ok {
+		return nil, &s3Types.NoSuchUpload{}
+	}
+	upload.parts[aws.ToInt32(input.PartNumber)] = body
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf(`"%x"`, md5.Sum(body)))}, nil
+}
+
+func (f *fakeS3Handler) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	upload, ok := f.uploads[aws.ToString(input.UploadId)]
+	if !ok {
+		return nil, &s3Types.NoSuchUpload{}
+	}
+	var body []byte
+	var partSizes []int64
+	sums := md5.New()
+	for _, part := range input.MultipartUpload.Parts {
+		data, ok := upload.parts[aws.ToInt32(part.PartNumber)]
+		if !ok || fmt.Sprintf(`"%x"`, md5.Sum(data)) != aws.ToString(part.ETag) {
+			return nil, &smithy.GenericAPIError{Code: "InvalidPart"}
+		}
+		body = append(body, data...)
+		partSizes = append(partSizes, int64(len(data)))
+		sum := md5.Sum(data)
+		sums.Write(sum[:])
+	}
+	delete(f.uploads, aws.ToString(input.UploadId))
+	obj := f.store(upload.bucket+"/"+upload.key, body)
+	obj.etag = fmt.Sprintf(`"%x-%d"`, sums.Sum(nil), len(partSizes))
+	obj.partSizes = partSizes
+	obj.headers = upload.headers
+	return &s3.CompleteMultipartUploadOutput{ETag: aws.String(obj.etag)}, nil
+}
+
+func (f *fakeS3Handler) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.uploads[aws.ToString(input.UploadId)]; !ok {
+		return nil, &s3Types.NoSuchUpload{}
+	}
+	delete(f.uploads, aws.ToString(input.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (f *fakeS3Handler) ListParts(ctx context.Context, input *s3.ListPartsInput) (*s3.ListPartsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	upload, ok := f.uploads[aws.ToString(input.UploadId)]
+	if !ok {
+		return nil, &s3Types.NoSuchUpload{}
+	}
+	output := &s3.ListPartsOutput{IsTruncated: aws.Bool(false)}
+	for number, data := range upload.parts {
+		output.Parts = append(output.Parts, s3Types.Part{
+			PartNumber: aws.Int32(number),
+			ETag:       aws.String(fmt.Sprintf(`"%x"`, md5.Sum(data))),
+			Size:       aws.Int64(int64(len(data))),
+		})
+	}
+	sort.Slice(output.Parts, func(i, j int) bool { return *output.Parts[i].PartNumber < *output.Parts[j].PartNumber })
+	return output, nil
+}
+
+func (f *fakeS3Handler) ListMultipartUploads(ctx context.Context, input *s3.ListMultipartUploadsInput) (*s3.ListMultipartUploadsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	output := &s3.ListMultipartUploadsOutput{IsTruncated: aws.Bool(false)}
+	for uploadID, upload := range f.uploads {
+		if upload.bucket == aws.ToString(input.Bucket) && strings.HasPrefix(upload.key, aws.ToString(input.Prefix)) {
+			output.Uploads = append(output.Uploads, s3Types.MultipartUpload{
+				Key:       aws.String(upload.key),
+				UploadId:  aws.String(uploadID),
+				Initiated: aws.Time(upload.initiated),
+			})
+		}
+	}
+	sort.Slice(output.Uploads, func(i, j int) bool { return *output.Uploads[i].UploadId < *output.Uploads[j].UploadId })
+	return output, nil
+}
+
+func (f *fakeS3Handler) CopyObject(ctx context.Context, input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	source, err := url.PathUnescape(aws.ToString(input.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[source]
+	if !ok {
+		return nil, &s3Types.NoSuchKey{}
+	}
+	copied := f.store(fakeS3ObjectID(input.Bucket, input.Key), obj.body)
+	copied.headers = obj.headers
+	return &s3.CopyObjectOutput{CopyObjectResult: &s3Types.CopyObjectResult{ETag: aws.String(copied.etag)}}, nil
+}
+
+func (f *fakeS3Handler) PutObjectTagging(ctx context.Context, input *s3.PutObjectTaggingInput) (*s3.PutObjectTaggingOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[fakeS3ObjectID(input.Bucket, input.Key)]
+	if !ok {
+		return nil, &s3Types.NoSuchKey{}
+	}
+	obj.headers.tags = map[string]string{}
+	for _, tag := range input.Tagging.TagSet {
+		obj.headers.tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+func (f *fakeS3Handler) GetObjectTagging(ctx context.Context, input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[fakeS3ObjectID(input.Bucket, input.Key)]
+	if !ok {
+		return nil, &s3Types.NoSuchKey{}
+	}
+	output := &s3.GetObjectTaggingOutput{TagSet: []s3Types.Tag{}}
+	for key, value := range obj.headers.tags {
+		output.TagSet = append(output.TagSet, s3Types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return output, nil
+}
+
+func (f *fakeS3Handler) RestoreObject(ctx context.Context, input *s3.RestoreObjectInput) (*s3.RestoreObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[fakeS3ObjectID(input.Bucket, input.Key)]
+	if !ok {
+		return nil, &s3Types.NoSuchKey{}
+	}
+	if !obj.archiveClass() {
+		return nil, &smithy.GenericAPIError{Code: "InvalidObjectState"}
+	}
+	if obj.restoreDays > 0 {
+		return nil, &smithy.GenericAPIError{Code: "RestoreAlreadyInProgress"}
+	}
+	days := aws.ToInt32(input.RestoreRequest.Days)
+	if !obj.restoreExpiry.IsZero() {
+		obj.restoreExpiry = f.now().UTC().Truncate(time.Second).AddDate(0, 0, int(days))
+	} else {
+		obj.restoreDays = days
+	}
+	return &s3.RestoreObjectOutput{}, nil
+}
+
+// completeRestore completes the restore of an object requested with RestoreObject
+func (f *fakeS3Handler) completeRestore(bucket, key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj := f.objects[bucket+"/"+key]
+	obj.restoreExpiry = f.now().UTC().Truncate(time.Second).AddDate(0, 0, int(obj.restoreDays))
+	obj.restoreDays = 0
+}
+
+func (o *fakeS3Object) archiveClass() bool {
+	return o.headers.storageClass == s3Types.StorageClassGlacier || o.headers.storageClass == s3Types.StorageClassDeepArchive
+}
+
+// archived reports whether the object can't be read without a restore
+func (o *fakeS3Object) archived() bool {
+	return o.archiveClass() && o.restoreExpiry.IsZero()
+}
+
+func (o *fakeS3Object) restoreHeader() *string {
+	switch {
+	case o.restoreDays > 0:
+		return aws.String(`ongoing-request="true"`)
+	case !o.restoreExpiry.IsZero():
+		return aws.String(fmt.Sprintf(`ongoing-request="false", expiry-date="%s"`, o.restoreExpiry.Format(http.TimeFormat)))
+	}
+	return nil
+}
+
+func (f *fakeS3Handler) PutObjectLegalHold(ctx context.Context, input *s3.PutObjectLegalHoldInput) (*s3.PutObjectLegalHoldOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[fakeS3ObjectID(input.Bucket, input.Key)]
+	if !ok {
+		return nil, &s3Types.NoSuchKey{}
+	}
+	obj.headers.legalHold = input.LegalHold.Status
+	return &s3.PutObjectLegalHoldOutput{}, nil
+}
@@ -0,0 +1,257 @@
+package pathio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultSFTPPort = "22"
+
+// SFTPConfig configures key-based authentication for sftp://user@host:port/path paths.
+type SFTPConfig struct {
+	// Signers are the private keys offered to the server.
+	Signers []ssh.Signer
+	// HostKeyCallback verifies the server's host key, e.g. knownhosts.New or ssh.FixedHostKey.
+	HostKeyCallback ssh.HostKeyCallback
+	// Timeout limits the time spent establishing the SSH connection. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// isSFTPPath reports whether the path is an sftp:// URL
+func isSFTPPath(path string) bool {
+	return hasScheme(path, schemeSFTP)
+}
+
+type sftpConnection struct {
+	client *sftp.Client
+	conn   *ssh.Client
+	path   string
+}
+
+// Close closes the SFTP session and the underlying SSH connection
+func (sftpConn sftpConnection) Close() error {
+	err := sftpConn.client.Close()
+	if connErr := sftpConn.conn.Close(); err == nil && !errors.Is(connErr, net.ErrClosed) {
+		err = connErr
+	}
+	return err
+}
+
+// parseSFTPPath parses an SFTP path (sftp://user@host:port/path) and returns a user, address, path tuple
+func parseSFTPPath(path string) (string, string, string, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid sftp path %s: %s", path, err)
+	}
+	if u.User == nil || u.User.Username() == "" || u.Hostname() == "" || u.Path == "" {
+		return "", "", "", fmt.Errorf("invalid sftp path %s", path)
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultSFTPPort
+	} else if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return "", "", "", fmt.Errorf("invalid sftp path %s", path)
+	}
+	return u.User.Username(), net.JoinHostPort(u.Hostname(), port), u.Path, nil
+}
+
+// sftpConnectionInformation parses the sftp path and opens an SFTP session to its host.
+// It is the caller's responsibility to close the returned connection.
+func (c *Client) sftpConnectionInformation(path string) (sftpConnection, error) {
+	user, addr, remotePath, err := parseSFTPPath(path)
+	if err != nil {
+		return sftpConnection{}, err
+	}
+	if c.SFTP == nil || c.SFTP.HostKeyCallback == nil {
+		return sftpConnection{}, fmt.Errorf("sftp paths require Client.SFTP with a HostKeyCallback")
+	}
+	conn, err := c.dialSSH(addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(c.SFTP.Signers...)},
+		HostKeyCallback: c.SFTP.HostKeyCallback,
+		Timeout:         c.SFTP.Timeout,
+	})
+	if err != nil {
+		return sftpConnection{}, fmt.Errorf("failed to connect to %s: %s", addr, err)
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return sftpConnection{}, fmt.Errorf("failed to start sftp session with %s: %s", addr, err)
+	}
+	return sftpConnection{client, conn, remotePath}, nil
+}
+
+// dialSSH opens an SSH connection to addr, giving up when the client's context is done or the
+// SFTP timeout expires
+func (c *Client) dialSSH(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	netConn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// The handshake isn't context aware, so the context closes the connection to interrupt it
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
+	if !stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// sftpFileReader converts an SFTP path into an io.ReadCloser. Closing it also closes the connection.
+func sftpFileReader(sftpConn sftpConnection) (io.ReadCloser, error) {
+	return sftpRangeReader(sftpConn, 0, -1)
+}
+
+// sftpRangeReader returns an io.ReadCloser for a byte range of an SFTP file. Closing it also
+// closes the connection.
+func sftpRangeReader(sftpConn sftpConnection, offset, length int64) (io.ReadCloser, error) {
+	file, err := sftpConn.client.Open(sftpConn.path)
+	if err != nil {
+		sftpConn.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		sftpConn.Close()
+		return nil, err
+	}
+	var reader io.Reader = file
+	if length >= 0 {
+		reader = io.LimitReader(file, length)
+	}
+	return readCloser{reader, closerFunc(func() error {
+		err := file.Close()
+		if connErr := sftpConn.Close(); err == nil {
+			err = connErr
+		}
+		return err
+	})}, nil
+}
+
+// closerFunc adapts a function to the io.Closer interface
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// writeToSFTP writes the input to a temporary file next to the destination and renames it
+// into place, so readers never observe a partially written file. Servers that don't advertise
+// the posix-rename extension fall back to removing the destination before renaming, which isn't
+// atomic.
+func writeToSFTP(sftpConn sftpConnection, input io.Reader) error {
+	dir, base := pathpkg.Split(sftpConn.path)
+	if err := sftpConn.client.MkdirAll(dir); err != nil {
+		return err
+	}
+	tmpPath := pathpkg.Join(dir, fmt.Sprintf(".%s.tmp-%d", base, time.Now().UnixNano()))
+	file, err := sftpConn.client.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := file.ReadFrom(input); err != nil {
+		file.Close()
+		sftpConn.client.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		sftpConn.client.Remove(tmpPath)
+		return err
+	}
+	if _, ok := sftpConn.client.HasExtension("posix-rename@openssh.com"); ok {
+		if err := sftpConn.client.PosixRename(tmpPath, sftpConn.path); err != nil {
+			sftpConn.client.Remove(tmpPath)
+			return err
+		}
+		return nil
+	}
+	if err := sftpConn.client.Remove(sftpConn.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		sftpConn.client.Remove(tmpPath)
+		return err
+	}
+	if err := sftpConn.client.Rename(tmpPath, sftpConn.path); err != nil {
+		sftpConn.client.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func lsSFTP(sftpConn sftpConnection) ([]string, error) {
+	resp, err := sftpConn.client.ReadDir(sftpConn.path)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]string, len(resp))
+	for i, val := range resp {
+		results[i] = val.Name()
+	}
+	return results, nil
+}
+
+func lsSFTPEntries(sftpConn sftpConnection, namePrefix string) ([]listEntry, error) {
+	resp, err := sftpConn.client.ReadDir(sftpConn.path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []listEntry
+	for _, val := range resp {
+		if !strings.HasPrefix(val.Name(), namePrefix) {
+			continue
+		}
+		entries = append(entries, listEntry{val.Name(), FileInfo{
+			Size:    val.Size(),
+			ModTime: val.ModTime(),
+			IsDir:   val.IsDir(),
+		}})
+	}
+	return entries, nil
+}
+
+func existsSFTP(sftpConn sftpConnection) (bool, error) {
+	_, err := sftpConn.client.Stat(sftpConn.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func statSFTP(sftpConn sftpConnection) (FileInfo, error) {
+	info, err := sftpConn.client.Stat(sftpConn.path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}, nil
+}
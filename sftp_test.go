@@ -0,0 +1,194 @@
+package pathio
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// newSFTPTestServer starts an in-process SSH server with the sftp subsystem that serves the
+// local file system, and returns its address and a Client authorized to use it.
+func newSFTPTestServer(t *testing.T) (string, *Client) {
+	_, hostPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivate)
+	require.NoError(t, err)
+	_, clientPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	clientSigner, err := ssh.NewSignerFromKey(clientPrivate)
+	require.NoError(t, err)
+
+	authorizedKey := string(clientSigner.PublicKey().Marshal())
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "district" && string(key.Marshal()) == authorizedKey {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown public key for %s", conn.User())
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, serverConfig)
+		}
+	}()
+
+	client := &Client{
+		ctx: context.Background(),
+		SFTP: &SFTPConfig{
+			Signers:         []ssh.Signer{clientSigner},
+			HostKeyCallback: ssh.FixedHostKey(hostSigner.PublicKey()),
+		},
+	}
+	return listener.Addr().String(), client
+}
+
+func serveSFTP(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+			}
+		}()
+		go func() {
+			defer channel.Close()
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+		}()
+	}
+}
+
+func TestParseSFTPPath(t *testing.T) {
+	user, addr, path, err := parseSFTPPath("sftp://district@example.com:2222/drops/roster.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "district", user)
+	assert.Equal(t, "example.com:2222", addr)
+	assert.Equal(t, "/drops/roster.csv", path)
+
+	_, addr, _, err = parseSFTPPath("sftp://district@example.com/roster.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com:22", addr)
+
+	_, _, _, err = parseSFTPPath("sftp://example.com/roster.csv")
+	assert.EqualError(t, err, "invalid sftp path sftp://example.com/roster.csv")
+
+	_, _, _, err = parseSFTPPath("sftp://district@example.com")
+	assert.EqualError(t, err, "invalid sftp path sftp://district@example.com")
+}
+
+func TestSFTPRoundTrip(t *testing.T) {
+	addr, client := newSFTPTestServer(t)
+	dir := t.TempDir()
+	path := fmt.Sprintf("sftp://district@%s%s/drops/roster.csv", addr, dir)
+
+	exists, err := client.Exists(path)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, client.Write(path, []byte("id,name\n1,ada\n")))
+	assert.NoError(t, client.Write(path, []byte("id,name\n2,grace\n")))
+
+	exists, err = client.Exists(path)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	reader, err := client.Reader(path)
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, "id,name\n2,grace\n", string(body))
+
+	reader, err = client.ReadRange(path, 8, 5)
+	require.NoError(t, err)
+	body, err = io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, "2,gra", string(body))
+
+	info, err := client.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(16), info.Size)
+
+	// The temporary file used for the atomic write is renamed away
+	files, err := client.ListFiles(fmt.Sprintf("sftp://district@%s%s/drops", addr, dir))
+	assert.NoError(t, err)
+	sort.Strings(files)
+	assert.Equal(t, []string{"roster.csv"}, files)
+	_, err = os.Stat(filepath.Join(dir, "drops", "roster.csv"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.Delete(path))
+	exists, err = client.Exists(path)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestSFTPRequiresConfig(t *testing.T) {
+	client := &Client{ctx: context.Background()}
+	_, err := client.Exists("sftp://district@127.0.0.1:1/file")
+	assert.EqualError(t, err, "sftp paths require Client.SFTP with a HostKeyCallback")
+}
+
+func TestSFTPDialStopsWithContext(t *testing.T) {
+	// A server that accepts connections but never answers the SSH handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client := &Client{ctx: ctx, SFTP: &SFTPConfig{HostKeyCallback: ssh.InsecureIgnoreHostKey()}}
+	start := time.Now()
+	_, err = client.Exists(fmt.Sprintf("sftp://district@%s/file", listener.Addr()))
+	assert.ErrorContains(t, err, context.DeadlineExceeded.Error())
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
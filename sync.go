@@ -0,0 +1,289 @@
+package pathio
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultSyncConcurrency is the number of files Sync compares and copies at once by default
+const defaultSyncConcurrency = 8
+
+// SyncCompare is how Sync decides whether a file needs to be copied.
+type SyncCompare int
+
+const (
+	// SyncSizeAndModTime copies files whose size differs or whose source was modified after the
+	// destination, to the second, like aws s3 sync.
+	SyncSizeAndModTime SyncCompare = iota
+	// SyncChecksum copies files whose size or MD5 differs. The MD5 of S3 objects is taken from
+	// their ETag when it is one, and other files are read to compute it.
+	SyncChecksum
+)
+
+// SyncOptions configures Sync.
+type SyncOptions struct {
+	Compare SyncCompare
+	// Delete removes the destination files that don't exist in the source.
+	Delete bool
+	// Include limits the sync to the files matching one of the globs, if set. Exclude skips the
+	// files matching one of its globs, both when copying and when deleting. Globs use path.Match
+	// syntax against the slash separated path relative to the root, and globs without a slash
+	// also match against the file name.
+	Include []string
+	Exclude []string
+	// DryRun computes what would be copied and deleted without changing anything.
+	DryRun bool
+	// Concurrency is the number of files compared and copied at once. It defaults to 8.
+	Concurrency int
+}
+
+// SyncResult lists what Sync did, with paths relative to the roots.
+type SyncResult struct {
+	Copied    []string
+	Deleted   []string
+	Unchanged int
+}
+
+// Sync makes the tree under dst mirror the tree under src, copying only the files that differ.
+// src and dst are directories or S3 prefixes on any path that can be listed; S3 prefixes are
+// listed recursively in one pass. Deletes are only done once every copy succeeded. Errors for
+// individual files are joined in the returned error, along with the result of what was done.
+func (c *Client) Sync(src, dst string, opts SyncOptions) (*SyncResult, error) {
+	for _, patterns := range [][]string{opts.Include, opts.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := pathpkg.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid sync glob %q: %s", pattern, err)
+			}
+		}
+	}
+	srcFiles, err := c.listTree(src)
+	if err != nil {
+		return nil, err
+	}
+	dstFiles, err := c.listTree(dst)
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+
+	var copies, deletes []string
+	for rel := range srcFiles {
+		if opts.selected(rel) {
+			copies = append(copies, rel)
+		}
+	}
+	if opts.Delete {
+		for rel := range dstFiles {
+			if _, ok := srcFiles[rel]; !ok && opts.selected(rel) {
+				deletes = append(deletes, rel)
+			}
+		}
+	}
+	sort.Strings(copies)
+	sort.Strings(deletes)
+
+	result := &SyncResult{}
+	var mu sync.Mutex
+	var errs []error
+	eachConcurrently(copies, opts.Concurrency, func(rel string) {
+		srcPath, dstPath := joinTreePath(src, rel), joinTreePath(dst, rel)
+		copied, err := c.syncFile(srcPath, dstPath, *srcFiles[rel], dstFiles[rel], opts)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to sync %s to %s: %s", srcPath, dstPath, err))
+		case copied:
+			result.Copied = append(result.Copied, rel)
+		default:
+			result.Unchanged++
+		}
+	})
+	if len(errs) == 0 {
+		eachConcurrently(deletes, opts.Concurrency, func(rel string) {
+			dstPath := joinTreePath(dst, rel)
+			var err error
+			if !opts.DryRun {
+				err = c.Delete(dstPath)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete %s: %s", dstPath, err))
+			} else {
+				result.Deleted = append(result.Deleted, rel)
+			}
+		})
+	}
+	sort.Strings(result.Copied)
+	sort.Strings(result.Deleted)
+	return result, errors.Join(errs...)
+}
+
+// selected reports whether the relative path passes the include and exclude globs
+func (opts SyncOptions) selected(rel string) bool {
+	if len(opts.Include) > 0 && !matchesAnyGlob(opts.Include, rel) {
+		return false
+	}
+	return !matchesAnyGlob(opts.Exclude, rel)
+}
+
+func matchesAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := pathpkg.Match(pattern, rel); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := pathpkg.Match(pattern, pathpkg.Base(rel)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eachConcurrently calls fn for every relative path, from concurrency goroutines
+func eachConcurrently(rels []string, concurrency int, fn func(rel string)) {
+	if concurrency <= 0 {
+		concurrency = defaultSyncConcurrency
+	}
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(concurrency, len(rels)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range work {
+				fn(rel)
+			}
+		}()
+	}
+	for _, rel := range rels {
+		work <- rel
+	}
+	close(work)
+	wg.Wait()
+}
+
+// syncFile copies srcPath to dstPath if they differ, and reports whether it did
+func (c *Client) syncFile(srcPath, dstPath string, srcInfo FileInfo, dstInfo *FileInfo, opts SyncOptions) (bool, error) {
+	if dstInfo != nil && dstInfo.Size == srcInfo.Size {
+		switch opts.Compare {
+		case SyncChecksum:
+			same, err := c.sameContent(srcPath, dstPath, srcInfo, *dstInfo)
+			if err != nil || same {
+				return false, err
+			}
+		default:
+			// S3 only keeps modification times to the second
+			if !srcInfo.ModTime.Truncate(time.Second).After(dstInfo.ModTime.Truncate(time.Second)) {
+				return false, nil
+			}
+		}
+	}
+	if opts.DryRun {
+		return true, nil
+	}
+	if c.DryRun != nil {
+		c.DryRun.record(DryRunEntry{Op: "copy", Path: srcPath, Dst: dstPath, Size: srcInfo.Size})
+		return true, nil
+	}
+	return true, c.audited(AuditRecord{Op: "copy", Path: dstPath, Src: srcPath}, func() error {
+		return c.copy(srcPath, dstPath, nil)
+	})
+}
+
+// checksum returns the hex MD5 of the file, from its ETag for S3 objects uploaded in one part
+func (c *Client) checksum(path string, info FileInfo) (string, error) {
+	if isS3Path(path) && md5ETagPattern.MatchString(info.ETag) {
+		return strings.Trim(info.ETag, `"`), nil
+	}
+	rc, err := c.reader(path)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// listTree returns the files under root by their slash separated path relative to root
+func (c *Client) listTree(root string) (map[string]*FileInfo, error) {
+	files := map[string]*FileInfo{}
+	if isS3Path(root) {
+		s3Conn, err := c.s3ConnectionInformation(root, c.Region)
+		if err != nil {
+			return nil, err
+		}
+		prefix := s3Conn.key
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		pages, err := s3Conn.handler.ListAllObjects(c.ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(s3Conn.bucket),
+			Prefix: aws.String(prefix),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range pages {
+			for _, val := range page.Contents {
+				rel := strings.TrimPrefix(aws.ToString(val.Key), prefix)
+				// Skip the objects marking directories
+				if rel == "" || strings.HasSuffix(rel, "/") {
+					continue
+				}
+				files[rel] = &FileInfo{
+					Size:    aws.ToInt64(val.Size),
+					ModTime: aws.ToTime(val.LastModified),
+					ETag:    aws.ToString(val.ETag),
+				}
+			}
+		}
+		return files, nil
+	}
+	return files, c.listTreeDir(root, "", files)
+}
+
+// listTreeDir adds the files under dir, which is rel relative to the root, by listing each
+// directory in turn
+func (c *Client) listTreeDir(dir, rel string, files map[string]*FileInfo) error {
+	entries, err := c.listDir(dir, "")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		entryRel := pathpkg.Join(rel, entry.name)
+		if entry.info.IsDir {
+			if err := c.listTreeDir(joinTreePath(dir, entry.name), entryRel, files); err != nil {
+				return err
+			}
+			continue
+		}
+		info := entry.info
+		files[entryRel] = &info
+	}
+	return nil
+}
+
+// joinTreePath joins a slash separated relative path to a root
+func joinTreePath(root, rel string) string {
+	if isS3Path(root) || isRemoteOnlyPath(root) {
+		return strings.TrimSuffix(root, "/") + "/" + rel
+	}
+	return filepath.Join(root, filepath.FromSlash(rel))
+}
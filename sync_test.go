@@ -0,0 +1,136 @@
+package pathio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestTree writes the files, keyed by slash separated relative path, under root
+func writeTestTree(t *testing.T, c *Client, root string, files map[string]string) {
+	for rel, body := range files {
+		require.NoError(t, c.Write(joinTreePath(root, rel), []byte(body)))
+	}
+}
+
+// readTestTree returns the content of the files under root by relative path
+func readTestTree(t *testing.T, c *Client, root string) map[string]string {
+	infos, err := c.listTree(root)
+	require.NoError(t, err)
+	files := map[string]string{}
+	for rel := range infos {
+		files[rel] = readFakeObject(t, c, joinTreePath(root, rel))
+	}
+	return files
+}
+
+func TestSync(t *testing.T) {
+	source := map[string]string{"a.txt": "a", "dir/b.csv": "bb", "dir/sub/c.txt": "ccc"}
+	tests := []struct {
+		desc     string
+		src, dst string
+	}{
+		{desc: "local to local", src: "local", dst: "local"},
+		{desc: "local to s3", src: "local", dst: "s3://bucket/dst"},
+		{desc: "s3 to local", src: "s3://bucket/src/", dst: "local"},
+		{desc: "s3 to s3", src: "s3://bucket/src", dst: "s3://other/"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := newFakeS3Client(newFakeS3Handler())
+			src, dst := test.src, test.dst
+			if src == "local" {
+				src = filepath.Join(t.TempDir(), "src")
+			}
+			if dst == "local" {
+				dst = filepath.Join(t.TempDir(), "dst")
+			}
+			writeTestTree(t, c, src, source)
+			writeTestTree(t, c, dst, map[string]string{"stale.txt": "x", "dir/b.csv": "bb"})
+
+			result, err := c.Sync(src, dst, SyncOptions{Delete: true})
+			require.NoError(t, err)
+			assert.Equal(t, &SyncResult{
+				Copied:    []string{"a.txt", "dir/sub/c.txt"},
+				Deleted:   []string{"stale.txt"},
+				Unchanged: 1,
+			}, result)
+			assert.Equal(t, source, readTestTree(t, c, dst))
+
+			result, err = c.Sync(src, dst, SyncOptions{Delete: true, Compare: SyncChecksum})
+			require.NoError(t, err)
+			assert.Equal(t, &SyncResult{Unchanged: 3}, result)
+		})
+	}
+}
+
+func TestSyncChecksum(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	src := t.TempDir()
+	writeTestTree(t, c, src, map[string]string{"same.txt": "same", "changed.txt": "new!"})
+	writeTestTree(t, c, "s3://bucket/dst", map[string]string{"same.txt": "same", "changed.txt": "old!"})
+
+	// The destination isn't older and has the same size, so only checksums tell them apart
+	result, err := c.Sync(src, "s3://bucket/dst", SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &SyncResult{Unchanged: 2}, result)
+
+	result, err = c.Sync(src, "s3://bucket/dst", SyncOptions{Compare: SyncChecksum})
+	require.NoError(t, err)
+	assert.Equal(t, &SyncResult{Copied: []string{"changed.txt"}, Unchanged: 1}, result)
+	assert.Equal(t, "new!", readFakeObject(t, c, "s3://bucket/dst/changed.txt"))
+}
+
+func TestSyncFilters(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	writeTestTree(t, c, "s3://bucket/src", map[string]string{
+		"a.csv": "a", "b.txt": "b", "logs/c.csv": "c", "logs/d.log": "d",
+	})
+	writeTestTree(t, c, "s3://bucket/dst", map[string]string{"keep.log": "k", "drop.csv": "x"})
+
+	tests := []struct {
+		desc string
+		opts SyncOptions
+		want *SyncResult
+	}{
+		{
+			desc: "include by file name",
+			opts: SyncOptions{Include: []string{"*.csv"}, Delete: true, DryRun: true},
+			want: &SyncResult{Copied: []string{"a.csv", "logs/c.csv"}, Deleted: []string{"drop.csv"}},
+		},
+		{
+			desc: "include by relative path",
+			opts: SyncOptions{Include: []string{"logs/*"}, DryRun: true},
+			want: &SyncResult{Copied: []string{"logs/c.csv", "logs/d.log"}},
+		},
+		{
+			desc: "exclude protects destination files from deletion",
+			opts: SyncOptions{Exclude: []string{"*.log"}, Delete: true, DryRun: true},
+			want: &SyncResult{Copied: []string{"a.csv", "b.txt", "logs/c.csv"}, Deleted: []string{"drop.csv"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			result, err := c.Sync("s3://bucket/src", "s3://bucket/dst", test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, result)
+			// Dry runs leave the destination alone
+			assert.Equal(t, map[string]string{"keep.log": "k", "drop.csv": "x"}, readTestTree(t, c, "s3://bucket/dst"))
+		})
+	}
+}
+
+func TestSyncErrors(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	_, err := c.Sync(filepath.Join(t.TempDir(), "missing"), t.TempDir(), SyncOptions{})
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = c.Sync(t.TempDir(), t.TempDir(), SyncOptions{Exclude: []string{"["}})
+	assert.Error(t, err)
+
+	_, err = c.Sync("https://example.com/dir", t.TempDir(), SyncOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package pathio
+
+import "os"
+
+// lockFile is a no-op on platforms without flock, so local conditional writes are only
+// serialized within a process there
+func lockFile(file *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without flock
+func unlockFile(file *os.File) error {
+	return nil
+}
+
+// fileID returns 0 on platforms without inode numbers
+func fileID(info os.FileInfo) uint64 {
+	return 0
+}
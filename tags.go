@@ -0,0 +1,119 @@
+package pathio
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// SetTags replaces the tags of the S3 object or local file at path. Local files keep their tags
+// in the same hidden sidecar file as WriteOptions, and other paths return an error wrapping
+// errors.ErrUnsupported. Writing a file without WriteOptions.Tags removes its tags, as it does
+// on S3.
+func (c *Client) SetTags(path string, tags map[string]string) error {
+	if isRemoteOnlyPath(path) {
+		return errTagsUnsupported(path)
+	}
+	if c.DryRun != nil {
+		c.DryRun.record(DryRunEntry{Op: "tag", Path: path})
+		return nil
+	}
+	return c.audited(AuditRecord{Op: "tag", Path: path}, func() error { return c.setTags(path, tags) })
+}
+
+// setTags implements SetTags
+func (c *Client) setTags(path string, tags map[string]string) error {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return err
+		}
+		tagSet := []s3Types.Tag{}
+		for key, value := range tags {
+			tagSet = append(tagSet, s3Types.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+		_, err = s3Conn.handler.PutObjectTagging(c.ctx, &s3.PutObjectTaggingInput{
+			Bucket:  aws.String(s3Conn.bucket),
+			Key:     aws.String(s3Conn.key),
+			Tagging: &s3Types.Tagging{TagSet: tagSet},
+		})
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	sidecar, err := readLocalSidecar(path)
+	if err != nil {
+		return err
+	}
+	sidecar.Tags = tags
+	return c.saveLocalSidecar(path, sidecar)
+}
+
+// GetTags returns the tags of the S3 object or local file at path, which are empty if it has
+// none.
+func (c *Client) GetTags(path string) (map[string]string, error) {
+	if isS3Path(path) {
+		s3Conn, err := c.s3ObjectConnectionInformation(path, c.Region)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s3Conn.handler.GetObjectTagging(c.ctx, &s3.GetObjectTaggingInput{
+			Bucket: aws.String(s3Conn.bucket),
+			Key:    aws.String(s3Conn.key),
+		})
+		if err != nil {
+			return nil, err
+		}
+		tags := map[string]string{}
+		for _, tag := range resp.TagSet {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+		return tags, nil
+	}
+	if isRemoteOnlyPath(path) {
+		return nil, errTagsUnsupported(path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	sidecar, err := readLocalSidecar(path)
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]string{}
+	for key, value := range sidecar.Tags {
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// encodeTagging encodes tags as the URL query S3 expects in the Tagging parameter of a write
+func encodeTagging(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = escapeTag(key) + "=" + escapeTag(tags[key])
+	}
+	return strings.Join(pairs, "&")
+}
+
+// escapeTag escapes a tag key or value, with spaces as %20
+func escapeTag(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func errTagsUnsupported(path string) error {
+	return fmt.Errorf("%w: tags are only supported on S3 and local paths, got: %s", errors.ErrUnsupported, path)
+}
@@ -0,0 +1,72 @@
+package pathio
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		desc string
+		path string
+	}{
+		{desc: "s3", path: "s3://bucket/reports/2024.csv"},
+		{desc: "local", path: "local"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			c := newFakeS3Client(newFakeS3Handler())
+			path := test.path
+			if path == "local" {
+				path = filepath.Join(t.TempDir(), "2024.csv")
+			}
+
+			require.NoError(t, c.WriteWithOptions(path, []byte("a,b"), WriteOptions{
+				CacheControl: "no-cache",
+				Tags:         map[string]string{"team": "data eng", "cost-center": "a&b=c"},
+			}))
+			tags, err := c.GetTags(path)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"team": "data eng", "cost-center": "a&b=c"}, tags)
+
+			// Setting tags replaces them and keeps the other options
+			require.NoError(t, c.SetTags(path, map[string]string{"retention": "30d"}))
+			tags, err = c.GetTags(path)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"retention": "30d"}, tags)
+			info, err := c.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, "no-cache", info.CacheControl)
+
+			// A plain write removes them
+			require.NoError(t, c.Write(path, []byte("c,d")))
+			tags, err = c.GetTags(path)
+			require.NoError(t, err)
+			assert.Empty(t, tags)
+			assert.NotNil(t, tags)
+		})
+	}
+}
+
+func TestTagsErrors(t *testing.T) {
+	c := newFakeS3Client(newFakeS3Handler())
+	missing := filepath.Join(t.TempDir(), "missing")
+	assert.True(t, isNotExist(c.SetTags(missing, map[string]string{"a": "b"})))
+	_, err := c.GetTags(missing)
+	assert.True(t, isNotExist(err))
+	_, err = c.GetTags("s3://bucket/missing")
+	assert.True(t, isNotExist(err))
+
+	assert.True(t, errors.Is(c.SetTags("https://example.com/file", nil), errors.ErrUnsupported))
+	_, err = c.GetTags("sftp://host/file")
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}
+
+func TestEncodeTagging(t *testing.T) {
+	assert.Equal(t, "", encodeTagging(nil))
+	assert.Equal(t, "a=1&team=data%20eng&x%26y=%3D", encodeTagging(map[string]string{"team": "data eng", "a": "1", "x&y": "="}))
+}
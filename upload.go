@@ -0,0 +1,303 @@
+package pathio
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// uploadStateFileMode is the permission of resumable upload state files
+const uploadStateFileMode os.FileMode = 0600
+
+// uploadState is the content of the state file of a resumable upload
+type uploadState struct {
+	Bucket   string         `json:"bucket"`
+	Key      string         `json:"key"`
+	UploadID string         `json:"upload_id"`
+	Size     int64          `json:"size"`
+	PartSize int64          `json:"part_size"`
+	Parts    []uploadedPart `json:"parts"`
+}
+
+// uploadedPart records a part that was uploaded, along with the MD5 of its data so a resumed
+// upload can tell whether the input still has the same content
+type uploadedPart struct {
+	PartNumber int32  `json:"part_number"`
+	ETag       string `json:"etag"`
+	MD5        string `json:"md5"`
+}
+
+func (c *Client) uploadPartSize() int64 {
+	if c.UploadPartSize > 0 {
+		return c.UploadPartSize
+	}
+	return manager.DefaultUploadPartSize
+}
+
+// uploadStatePath returns the state file of uploads to the S3 object
+func (c *Client) uploadStatePath(bucket, key string) string {
+	sum := sha256.Sum256([]byte(bucket + "/" + key))
+	return filepath.Join(c.ResumableUploadDir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// writeToS3Resumable uploads the input to S3 as a multipart upload whose progress is saved to
+// a state file. If a state file for the same object, size and part size exists and its upload
+// is still in progress, parts that S3 has and whose data hasn't changed are skipped. Inputs
+// that fit in a single part are uploaded with PutObject.
+func (c *Client) writeToS3Resumable(s3Conn s3Connection, input io.ReadSeeker, progress *progressTracker, opts *WriteOptions) error {
+	size, err := input.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	partSize := c.uploadPartSize()
+	if size <= partSize {
+		if progress != nil {
+			input = &progressReadSeeker{ReadSeeker: input, progress: progress}
+		}
+		return writeToS3WithOptions(c.ctx, s3Conn, input, c.disableS3Encryption, opts)
+	}
+	if (size+partSize-1)/partSize > int64(manager.MaxUploadParts) {
+		return fmt.Errorf("can't upload %d bytes to %s in parts of %d bytes: S3 allows at most %d parts", size, s3Conn.path(), partSize, manager.MaxUploadParts)
+	}
+
+	statePath := c.uploadStatePath(s3Conn.bucket, s3Conn.key)
+	state, uploaded, err := resumeUploadState(c.ctx, s3Conn, statePath, size, partSize)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		params := &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(s3Conn.bucket),
+			Key:    aws.String(s3Conn.key),
+		}
+		if !c.disableS3Encryption {
+			params.ServerSideEncryption = aesAlgo
+		}
+		opts.applyToCreateMultipartUpload(params)
+		resp, err := s3Conn.handler.CreateMultipartUpload(c.ctx, params)
+		if err != nil {
+			return err
+		}
+		state = &uploadState{
+			Bucket:   s3Conn.bucket,
+			Key:      s3Conn.key,
+			UploadID: aws.ToString(resp.UploadId),
+			Size:     size,
+			PartSize: partSize,
+		}
+		if err := c.saveUploadState(statePath, state); err != nil {
+			return err
+		}
+	}
+
+	parts := map[int32]uploadedPart{}
+	for _, part := range state.Parts {
+		parts[part.PartNumber] = part
+	}
+	buf := make([]byte, partSize)
+	var completed []s3Types.CompletedPart
+	for partNumber, offset := int32(1), int64(0); offset < size; partNumber, offset = partNumber+1, offset+partSize {
+		data := buf[:min(partSize, size-offset)]
+		if _, err := io.ReadFull(input, data); err != nil {
+			return err
+		}
+		sum := md5.Sum(data)
+		contentMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+		part, ok := parts[partNumber]
+		if !ok || part.MD5 != contentMD5 || uploaded[partNumber] != part.ETag {
+			resp, err := s3Conn.handler.UploadPart(c.ctx, &s3.UploadPartInput{
+				Bucket:        aws.String(s3Conn.bucket),
+				Key:           aws.String(s3Conn.key),
+				UploadId:      aws.String(state.UploadID),
+				PartNumber:    aws.Int32(partNumber),
+				Body:          bytes.NewReader(data),
+				ContentLength: aws.Int64(int64(len(data))),
+				ContentMD5:    aws.String(contentMD5),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to upload part %d of %s, write it again to resume: %s", partNumber, s3Conn.path(), err)
+			}
+			part = uploadedPart{PartNumber: partNumber, ETag: aws.ToString(resp.ETag), MD5: contentMD5}
+			parts[partNumber] = part
+			state.Parts = sortedUploadedParts(parts)
+			if err := c.saveUploadState(statePath, state); err != nil {
+				return err
+			}
+		}
+		progress.add(int64(len(data)))
+		completed = append(completed, s3Types.CompletedPart{PartNumber: aws.Int32(partNumber), ETag: aws.String(part.ETag)})
+	}
+
+	_, err = s3Conn.handler.CompleteMultipartUpload(c.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s3Conn.bucket),
+		Key:             aws.String(s3Conn.key),
+		UploadId:        aws.String(state.UploadID),
+		MultipartUpload: &s3Types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil && !isNoSuchUpload(err) {
+		return err
+	}
+	if removeErr := os.Remove(statePath); removeErr != nil && !os.IsNotExist(removeErr) && err == nil {
+		return removeErr
+	}
+	return err
+}
+
+// resumeUploadState loads the state file at statePath and lists the parts S3 has for its
+// upload. It returns a nil state if there is nothing to resume.
+func resumeUploadState(ctx context.Context, s3Conn s3Connection, statePath string, size, partSize int64) (*uploadState, map[int32]string, error) {
+	body, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	var state uploadState
+	if err := json.Unmarshal(body, &state); err != nil {
+		return nil, nil, fmt.Errorf("invalid upload state file %s: %s", statePath, err)
+	}
+	if state.Bucket != s3Conn.bucket || state.Key != s3Conn.key || state.Size != size || state.PartSize != partSize {
+		// The input changed size, so none of the parts can be reused. The old upload is left
+		// for AbortStaleUploads.
+		return nil, nil, nil
+	}
+	uploaded, err := listUploadedParts(ctx, s3Conn, state.UploadID)
+	if isNoSuchUpload(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return &state, uploaded, nil
+}
+
+// listUploadedParts returns the ETags of the parts of a multipart upload by part number
+func listUploadedParts(ctx context.Context, s3Conn s3Connection, uploadID string) (map[int32]string, error) {
+	parts := map[int32]string{}
+	params := &s3.ListPartsInput{
+		Bucket:   aws.String(s3Conn.bucket),
+		Key:      aws.String(s3Conn.key),
+		UploadId: aws.String(uploadID),
+	}
+	for {
+		resp, err := s3Conn.handler.ListParts(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range resp.Parts {
+			parts[aws.ToInt32(part.PartNumber)] = aws.ToString(part.ETag)
+		}
+		if !aws.ToBool(resp.IsTruncated) {
+			return parts, nil
+		}
+		params.PartNumberMarker = resp.NextPartNumberMarker
+	}
+}
+
+func sortedUploadedParts(parts map[int32]uploadedPart) []uploadedPart {
+	sorted := make([]uploadedPart, 0, len(parts))
+	for _, part := range parts {
+		sorted = append(sorted, part)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+	return sorted
+}
+
+func (c *Client) saveUploadState(statePath string, state *uploadState) error {
+	body, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeToLocalFile(statePath, bytes.NewReader(body), uploadStateFileMode, c.dirMode())
+}
+
+func isNoSuchUpload(err error) bool {
+	var noSuchUpload *s3Types.NoSuchUpload
+	return errors.As(err, &noSuchUpload)
+}
+
+// AbortStaleUploads aborts the incomplete multipart uploads under an S3 path that were started
+// more than olderThan ago, and removes their state files from ResumableUploadDir. It returns
+// the paths of the aborted uploads. Incomplete uploads are billed for their parts until they
+// are aborted.
+func (c *Client) AbortStaleUploads(path string, olderThan time.Duration) ([]string, error) {
+	if !isS3Path(path) {
+		return nil, fmt.Errorf("%w: multipart uploads only exist for s3 paths, got: %s", errors.ErrUnsupported, path)
+	}
+	s3Conn, err := c.s3ConnectionInformation(path, c.Region)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	params := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s3Conn.bucket),
+		Prefix: aws.String(s3Conn.key),
+	}
+	var aborted []string
+	for {
+		resp, err := s3Conn.handler.ListMultipartUploads(c.ctx, params)
+		if err != nil {
+			return aborted, err
+		}
+		for _, upload := range resp.Uploads {
+			if upload.Initiated == nil || !upload.Initiated.Before(cutoff) {
+				continue
+			}
+			uploadPath := "s3://" + s3Conn.bucket + "/" + aws.ToString(upload.Key)
+			err := c.audited(AuditRecord{Op: "abort-upload", Path: uploadPath}, func() error {
+				_, err := s3Conn.handler.AbortMultipartUpload(c.ctx, &s3.AbortMultipartUploadInput{
+					Bucket:   aws.String(s3Conn.bucket),
+					Key:      upload.Key,
+					UploadId: upload.UploadId,
+				})
+				if isNoSuchUpload(err) {
+					return nil
+				}
+				return err
+			})
+			if err != nil {
+				return aborted, err
+			}
+			aborted = append(aborted, uploadPath)
+			if c.ResumableUploadDir != "" {
+				c.removeUploadState(s3Conn.bucket, aws.ToString(upload.Key), aws.ToString(upload.UploadId))
+			}
+		}
+		if !aws.ToBool(resp.IsTruncated) {
+			return aborted, nil
+		}
+		params.KeyMarker, params.UploadIdMarker = resp.NextKeyMarker, resp.NextUploadIdMarker
+	}
+}
+
+// removeUploadState removes the state file of the object if it belongs to the upload
+func (c *Client) removeUploadState(bucket, key, uploadID string) {
+	statePath := c.uploadStatePath(bucket, key)
+	body, err := os.ReadFile(statePath)
+	if err != nil {
+		return
+	}
+	var state uploadState
+	if json.Unmarshal(body, &state) == nil && state.UploadID == uploadID {
+		os.Remove(statePath)
+	}
+}
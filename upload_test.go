@@ -0,0 +1,152 @@
+package pathio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interruptedS3Handler fails UploadPart for the given part number until it is cleared, and
+// records the parts that were uploaded
+type interruptedS3Handler struct {
+	*fakeS3Handler
+	mu       sync.Mutex
+	failPart int32
+	uploaded []int32
+}
+
+func (h *interruptedS3Handler) UploadPart(ctx context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if aws.ToInt32(input.PartNumber) == h.failPart {
+		return nil, errors.New("connection reset by peer")
+	}
+	h.uploaded = append(h.uploaded, aws.ToInt32(input.PartNumber))
+	return h.fakeS3Handler.UploadPart(ctx, input)
+}
+
+func (h *interruptedS3Handler) resetUploaded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.uploaded = nil
+}
+
+func newResumableTestClient(t *testing.T, handler s3Handler) *Client {
+	return &Client{
+		ctx:                context.Background(),
+		handler:            handler,
+		ResumableUploadDir: t.TempDir(),
+		UploadPartSize:     1000,
+	}
+}
+
+func readFakeObject(t *testing.T, c *Client, path string) string {
+	rc, err := c.Reader(path)
+	require.NoError(t, err)
+	defer rc.Close()
+	body, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestResumableUpload(t *testing.T) {
+	handler := &interruptedS3Handler{fakeS3Handler: newFakeS3Handler(), failPart: 3}
+	c := newResumableTestClient(t, handler)
+	input := strings.Repeat("a", 1000) + strings.Repeat("b", 1000) + strings.Repeat("c", 1000) + strings.Repeat("d", 500)
+
+	err := c.WriteReader("s3://bucket/snapshot", strings.NewReader(input))
+	assert.ErrorContains(t, err, "failed to upload part 3 of s3://bucket/snapshot")
+	assert.Equal(t, []int32{1, 2}, handler.uploaded)
+	exists, err := c.Exists("s3://bucket/snapshot")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// A new process resumes from the state file
+	handler.failPart = 0
+	handler.resetUploaded()
+	c = &Client{ctx: context.Background(), handler: handler, ResumableUploadDir: c.ResumableUploadDir, UploadPartSize: 1000}
+	require.NoError(t, c.WriteReader("s3://bucket/snapshot", strings.NewReader(input)))
+	assert.Equal(t, []int32{3, 4}, handler.uploaded)
+	assert.Equal(t, input, readFakeObject(t, c, "s3://bucket/snapshot"))
+
+	entries, err := os.ReadDir(c.ResumableUploadDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the state file is removed once the upload completes")
+	assert.Empty(t, handler.uploads)
+}
+
+func TestResumableUploadChangedInput(t *testing.T) {
+	handler := &interruptedS3Handler{fakeS3Handler: newFakeS3Handler(), failPart: 3}
+	c := newResumableTestClient(t, handler)
+	input := []byte(strings.Repeat("x", 3000))
+
+	assert.Error(t, c.WriteReader("s3://bucket/snapshot", bytes.NewReader(input)))
+
+	// Part 2 changed since the interrupted upload, so only part 1 is reused
+	handler.failPart = 0
+	handler.resetUploaded()
+	input[1500] = 'y'
+	require.NoError(t, c.WriteReader("s3://bucket/snapshot", bytes.NewReader(input)))
+	assert.Equal(t, []int32{2, 3}, handler.uploaded)
+	assert.Equal(t, string(input), readFakeObject(t, c, "s3://bucket/snapshot"))
+}
+
+func TestResumableUploadExpiredUpload(t *testing.T) {
+	handler := &interruptedS3Handler{fakeS3Handler: newFakeS3Handler(), failPart: 2}
+	c := newResumableTestClient(t, handler)
+	input := strings.Repeat("z", 2500)
+
+	assert.Error(t, c.WriteReader("s3://bucket/snapshot", strings.NewReader(input)))
+	aborted, err := c.AbortStaleUploads("s3://bucket/", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s3://bucket/snapshot"}, aborted)
+	entries, err := os.ReadDir(c.ResumableUploadDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	handler.failPart = 0
+	handler.resetUploaded()
+	require.NoError(t, c.WriteReader("s3://bucket/snapshot", strings.NewReader(input)))
+	assert.Equal(t, []int32{1, 2, 3}, handler.uploaded)
+	assert.Equal(t, input, readFakeObject(t, c, "s3://bucket/snapshot"))
+}
+
+func TestResumableUploadSmallInput(t *testing.T) {
+	handler := &interruptedS3Handler{fakeS3Handler: newFakeS3Handler()}
+	c := newResumableTestClient(t, handler)
+	require.NoError(t, c.WriteReader("s3://bucket/small", strings.NewReader("small")))
+	assert.Empty(t, handler.uploaded)
+	assert.Equal(t, "small", readFakeObject(t, c, "s3://bucket/small"))
+}
+
+func TestAbortStaleUploads(t *testing.T) {
+	handler := newFakeS3Handler()
+	c := newFakeS3Client(handler)
+	handler.now = func() time.Time { return time.Now().Add(-10 * 24 * time.Hour) }
+	_, err := handler.CreateMultipartUpload(c.ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("exports/old")})
+	require.NoError(t, err)
+	_, err = handler.CreateMultipartUpload(c.ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("other/old")})
+	require.NoError(t, err)
+	handler.now = time.Now
+	_, err = handler.CreateMultipartUpload(c.ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("exports/new")})
+	require.NoError(t, err)
+
+	aborted, err := c.AbortStaleUploads("s3://bucket/exports/", 7*24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s3://bucket/exports/old"}, aborted)
+	assert.Len(t, handler.uploads, 2)
+
+	_, err = c.AbortStaleUploads("/tmp/exports", time.Hour)
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}